@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+	"github.com/takutakahashi/operation-mcp/pkg/diff"
+)
+
+// lintIssue is the JSON representation of a configuration issue
+type lintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// newConfigCommand creates the config command and its subcommands
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration file",
+		// The config commands load the file themselves, so that an invalid
+		// configuration can still be inspected
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(newConfigLintCommand())
+	configCmd.AddCommand(newConfigSchemaCommand())
+	configCmd.AddCommand(newConfigMigrateCommand())
+
+	return configCmd
+}
+
+// newConfigLintCommand creates the config lint command
+func newConfigLintCommand() *cobra.Command {
+	var format string
+
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the configuration file for errors",
+		Long:  `Check the configuration file and print every error and warning with its file, line and column.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			issues, err := lintConfig(configPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			switch format {
+			case "text":
+				for _, issue := range issues {
+					fmt.Printf("%s: %s: %s\n", issue.Pos, issue.Severity(), issue.Message)
+				}
+			case "json":
+				result := make([]lintIssue, 0, len(issues))
+				for _, issue := range issues {
+					result = append(result, lintIssue{
+						File:     issue.Pos.File,
+						Line:     issue.Pos.Line,
+						Column:   issue.Pos.Column,
+						Severity: issue.Severity(),
+						Message:  issue.Message,
+					})
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(result); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			default:
+				fmt.Fprintf(os.Stderr, "Error: unknown format: %s\n", format)
+				os.Exit(1)
+			}
+
+			for _, issue := range issues {
+				if !issue.Warning {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	lintCmd.Flags().StringVar(&format, "format", "text", "Output format (text or json)")
+
+	return lintCmd
+}
+
+// newConfigSchemaCommand creates the config schema command
+func newConfigSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration file",
+		Long:  `Print the JSON Schema of the configuration file, for completion and validation in editors.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			schema, err := config.Schema()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(schema))
+		},
+	}
+}
+
+// newConfigMigrateCommand creates the config migrate command
+func newConfigMigrateCommand() *cobra.Command {
+	var dryRun bool
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the configuration file to the current format version",
+		Long: `Upgrade the configuration file and the files it includes to the current format version.
+The changes are shown as a diff before the files are rewritten in place. Comments are kept.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := config.LoadConfigFormat(configPath, config.Format(configFormat))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			migrated := 0
+			for i, file := range cfg.Files {
+				format := config.FormatFromPath(file)
+				if i == 0 && configFormat != "" {
+					format = config.Format(configFormat)
+				}
+				if format != config.FormatYAML {
+					// JSON and TOML files are usually generated, so they are
+					// left to the tools that produce them
+					fmt.Fprintf(os.Stderr, "Skipping %s: only YAML files can be migrated\n", file)
+					continue
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				out, changes, err := config.Migrate(data)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s: %v\n", file, err)
+					os.Exit(1)
+				}
+				if len(changes) == 0 {
+					continue
+				}
+				migrated++
+
+				for _, change := range changes {
+					fmt.Printf("%s: %s\n", file, change)
+				}
+				fmt.Print(diff.Unified(file, string(data), string(out)))
+
+				if dryRun {
+					continue
+				}
+				info, err := os.Stat(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if err := os.WriteFile(file, out, info.Mode()); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			switch {
+			case migrated == 0:
+				fmt.Printf("Configuration is already at version %d\n", config.CurrentVersion)
+			case dryRun:
+				fmt.Printf("%d file(s) would be migrated to version %d\n", migrated, config.CurrentVersion)
+			default:
+				fmt.Printf("Migrated %d file(s) to version %d\n", migrated, config.CurrentVersion)
+			}
+		},
+	}
+
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without writing the files")
+
+	return migrateCmd
+}
+
+// lintConfig loads the configuration and returns all issues found in it.
+// Errors that prevent the file from being parsed are returned as issues too.
+func lintConfig(path string) ([]config.Issue, error) {
+	cfg, err := config.LoadConfigFormat(path, config.Format(configFormat))
+	if err != nil {
+		var validationErrs config.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			return validationErrs, nil
+		}
+		return nil, err
+	}
+
+	return cfg.Lint(), nil
+}
@@ -4,9 +4,53 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/takutakahashi/operation-mcp/pkg/config"
 	"github.com/takutakahashi/operation-mcp/pkg/executor"
+	"github.com/takutakahashi/operation-mcp/pkg/tool"
 )
 
+// newToolManager creates the tool manager and sets where tools run based on
+// command-line flags. --remote runs every tool on the host given by the ssh
+// flags and settings; --targets runs tools on several targets at once;
+// otherwise tools run on their configured targets, or on the one given with
+// --target.
+func newToolManager(cfg *config.Config) (*tool.Manager, error) {
+	mgr := tool.NewManager(cfg)
+
+	selected := 0
+	for _, set := range []bool{remoteMode, targetName != "", targetSelector != ""} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return nil, fmt.Errorf("only one of --remote, --target and --targets can be used")
+	}
+
+	if targetSelector != "" {
+		mgr.WithTargetSelector(targetSelector, fanOut)
+		return mgr, nil
+	}
+
+	if remoteMode {
+		exec, err := createExecutor()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create executor: %w", err)
+		}
+		mgr.WithExecutor(exec)
+		return mgr, nil
+	}
+
+	if targetName != "" && targetName != config.LocalTarget {
+		if _, ok := cfg.Targets[targetName]; !ok {
+			return nil, fmt.Errorf("target not found: %s", targetName)
+		}
+	}
+	mgr.WithTarget(targetName)
+
+	return mgr, nil
+}
+
 // createExecutor creates an executor based on command-line flags
 func createExecutor() (executor.Executor, error) {
 	// If remote mode is not enabled, use a local executor
@@ -24,6 +68,9 @@ func createExecutor() (executor.Executor, error) {
 		sshConfig.Host = cfg.SSH.Host
 	}
 
+	// Settings of ~/.ssh/config for the host come before the explicit ones
+	executor.ApplyOpenSSHConfig(sshConfig)
+
 	if sshUser != "" {
 		sshConfig.User = sshUser
 	} else if cfg != nil && cfg.SSH.User != "" {
@@ -67,6 +114,36 @@ func createExecutor() (executor.Executor, error) {
 		sshConfig.Timeout = time.Duration(cfg.SSH.Timeout) * time.Second
 	}
 
+	// Jump hosts, key and host key settings come from the config file only
+	if cfg != nil && cfg.SSH != nil {
+		if len(cfg.SSH.ProxyJump) > 0 {
+			sshConfig.ProxyJump = nil
+			for i := range cfg.SSH.ProxyJump {
+				hop := executor.SSHConfigConverter(&cfg.SSH.ProxyJump[i])
+				hop.ProxyJump = nil
+				sshConfig.ProxyJump = append(sshConfig.ProxyJump, hop)
+			}
+		}
+		if cfg.SSH.KeyPassphrase != "" {
+			sshConfig.KeyPassphrase = cfg.SSH.KeyPassphrase
+		}
+		if cfg.SSH.Certificate != "" {
+			sshConfig.CertificatePath = cfg.SSH.Certificate
+		}
+		if cfg.SSH.Agent != nil {
+			sshConfig.UseAgent = *cfg.SSH.Agent
+		}
+		if cfg.SSH.HostKeyPath != "" {
+			sshConfig.HostKeyPath = cfg.SSH.HostKeyPath
+		}
+		if cfg.SSH.HostKeyPolicy != "" {
+			sshConfig.HostKeyPolicy = cfg.SSH.HostKeyPolicy
+		}
+		if cfg.SSH.HostKeyFingerprint != "" {
+			sshConfig.HostKeyFingerprint = cfg.SSH.HostKeyFingerprint
+		}
+	}
+
 	// Validate the SSH config
 	if sshConfig.Host == "" {
 		return nil, fmt.Errorf("SSH host is required in remote mode")
@@ -13,9 +13,16 @@ import (
 )
 
 var (
-	configPath string
-	cfg        *config.Config
-	toolMgr    *tool.Manager
+	configPath   string
+	configFormat string
+	profileName  string
+	cfg          *config.Config
+	toolMgr      *tool.Manager
+
+	// 実行先のターゲット
+	targetName     string
+	targetSelector string
+	fanOut         config.FanOutConfig
 
 	// SSH関連のフラグ
 	remoteMode    bool
@@ -29,23 +36,37 @@ var (
 )
 
 func main() {
-	// Parse config from flags directly to handle it early
+	// Parse config and profile from flags directly to handle them early
+	profileName = os.Getenv(config.ProfileEnv)
 	for i, arg := range os.Args {
 		if strings.HasPrefix(arg, "--config=") {
 			configPath = strings.TrimPrefix(arg, "--config=")
-			break
 		} else if arg == "--config" && i+1 < len(os.Args) {
 			configPath = os.Args[i+1]
-			break
+		} else if strings.HasPrefix(arg, "--config-format=") {
+			configFormat = strings.TrimPrefix(arg, "--config-format=")
+		} else if arg == "--config-format" && i+1 < len(os.Args) {
+			configFormat = os.Args[i+1]
+		} else if strings.HasPrefix(arg, "--profile=") {
+			profileName = strings.TrimPrefix(arg, "--profile=")
+		} else if arg == "--profile" && i+1 < len(os.Args) {
+			profileName = os.Args[i+1]
 		}
 	}
 
 	// Try to load the config early
 	var err error
 	if configPath != "" {
-		cfg, err = config.LoadConfig(configPath)
+		cfg, err = config.LoadConfigFormat(configPath, config.Format(configFormat))
+		if err == nil {
+			err = cfg.ApplyProfile(profileName)
+		}
+		if err == nil {
+			err = cfg.Validate()
+		}
 		if err != nil {
 			fmt.Printf("Warning: Failed to load config from %s: %v\n", configPath, err)
+			cfg = nil
 		}
 	}
 
@@ -54,38 +75,48 @@ func main() {
 		Short: "Operations CLI tool",
 		Long:  "A CLI tool for executing operations defined in a configuration file",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			// If we already loaded the config, we can skip this
-			if cfg != nil {
-				return nil
-			}
+			// Load the config unless it was loaded early
+			if cfg == nil {
+				var err error
+				cfg, err = config.LoadConfigFormat(configPath, config.Format(configFormat))
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
 
-			var err error
-			cfg, err = config.LoadConfig(configPath)
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
+				if err := cfg.ApplyProfile(profileName); err != nil {
+					return fmt.Errorf("failed to apply profile: %w", err)
+				}
 
-			if err := cfg.Validate(); err != nil {
-				return fmt.Errorf("invalid configuration: %w", err)
+				if err := cfg.Validate(); err != nil {
+					return fmt.Errorf("invalid configuration: %w", err)
+				}
 			}
 
-			// Create and configure the tool manager
-			toolMgr = tool.NewManager(cfg)
-
-			// Create the appropriate executor based on flags
-			exec, err := createExecutor()
-			if err != nil {
-				return fmt.Errorf("failed to create executor: %w", err)
+			// Create the tool manager now that the flags selecting where
+			// tools run are parsed
+			var err error
+			toolMgr, err = newToolManager(cfg)
+			return err
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			// Close the connections to targets
+			if toolMgr != nil {
+				toolMgr.Close()
 			}
-
-			// Set executor for the tool manager
-			toolMgr.WithExecutor(exec)
-
-			return nil
 		},
 	}
 
-	rootCmd.PersistentFlags().StringVar(&configPath, "config", configPath, "path to config file")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", configPath, "path to config file (default: ~/.operations/config.yaml, .json or .toml, then operations.yaml, .json, .toml or config.yaml in the current directory)")
+	rootCmd.PersistentFlags().StringVar(&configFormat, "config-format", configFormat, "format of the config file: yaml, json or toml (detected from the extension if unset)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", profileName, "configuration profile to use (default from $"+config.ProfileEnv+")")
+
+	// Flags selecting the targets tools run on
+	rootCmd.PersistentFlags().StringVar(&targetName, "target", "", "name of the target to run tools on (overrides the target in the config)")
+	rootCmd.PersistentFlags().StringVar(&targetSelector, "targets", "", "run tools on several targets at once: a comma-separated list of names or of label requirements (e.g. role=app,env!=prod)")
+	rootCmd.PersistentFlags().IntVar(&fanOut.Concurrency, "concurrency", 0, "maximum number of targets to run on at once with --targets")
+	rootCmd.PersistentFlags().BoolVar(&fanOut.FailFast, "fail-fast", false, "stop starting new targets after the first failure with --targets")
+	rootCmd.PersistentFlags().IntVar(&fanOut.MaxFailures, "max-failures", 0, "stop starting new targets after this many failures with --targets")
+	rootCmd.PersistentFlags().IntVar(&fanOut.Timeout, "target-timeout", 0, "maximum time in seconds a tool may run on each target with --targets")
 
 	// SSH関連のフラグを追加
 	rootCmd.PersistentFlags().BoolVar(&remoteMode, "remote", false, "Enable remote execution mode via SSH")
@@ -143,7 +174,14 @@ func main() {
 
 			// Display tools
 			for _, tool := range tools {
-				fmt.Println(tool.Name)
+				// If verbose mode, display where the tool was defined and its own parameters
+				if verbose {
+					fmt.Printf("%s (from %s)\n", tool.Name, tool.Source)
+					printDescription(tool, "  ")
+					printParams(tool.Params, "  ")
+				} else {
+					fmt.Println(tool.Name)
+				}
 
 				// Display subtools recursively
 				printSubtools(tool.Subtools, 1, tool.Name, verbose)
@@ -158,20 +196,11 @@ func main() {
 
 	rootCmd.AddCommand(listCmd)
 
+	// Add the config command
+	rootCmd.AddCommand(newConfigCommand())
+
 	// If we have a config, add commands for each tool
 	if cfg != nil {
-		// Create and configure the tool manager
-		toolMgr = tool.NewManager(cfg)
-
-		// Create the appropriate executor based on flags
-		exec, err := createExecutor()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to create executor: %v\n", err)
-		} else {
-			// Set executor for the tool manager
-			toolMgr.WithExecutor(exec)
-		}
-
 		for _, tool := range cfg.Tools {
 			toolCmd := createToolCommand(tool)
 			rootCmd.AddCommand(toolCmd)
@@ -186,8 +215,11 @@ func main() {
 
 func createToolCommand(tool config.Tool) *cobra.Command {
 	toolCmd := &cobra.Command{
-		Use:   tool.Name,
-		Short: fmt.Sprintf("Execute %s command", tool.Name),
+		Use:     tool.Name,
+		Aliases: tool.Aliases,
+		Short:   shortDescription(tool.Description, tool.Name, tool.Deprecated),
+		Long:    longDescription(tool.Description, tool.LongDescription),
+		Example: formatExamples(tool.Examples),
 		Run: func(cmd *cobra.Command, args []string) {
 			// If no subtools, execute the tool directly
 			if len(tool.Subtools) == 0 {
@@ -236,7 +268,7 @@ func createToolCommand(tool config.Tool) *cobra.Command {
 				subtoolCmd.Flags().String(name, "", param.Description)
 			}
 
-			if param.Required {
+			if param.Required && !param.HasFallback() {
 				subtoolCmd.MarkFlagRequired(name)
 			}
 		}
@@ -253,8 +285,11 @@ func createSubtoolCommand(parentName string, subtool config.Subtool) *cobra.Comm
 	fullName := parentName + "_" + name
 
 	subtoolCmd := &cobra.Command{
-		Use:   name,
-		Short: fmt.Sprintf("Execute %s command", fullName),
+		Use:     name,
+		Aliases: commandAliases(subtool.Aliases),
+		Short:   shortDescription(subtool.Description, fullName, subtool.Deprecated),
+		Long:    longDescription(subtool.Description, subtool.LongDescription),
+		Example: formatExamples(subtool.Examples),
 		Run: func(cmd *cobra.Command, args []string) {
 			// If no subtools, execute the subtool
 			if len(subtool.Subtools) == 0 {
@@ -284,6 +319,47 @@ func createSubtoolCommand(parentName string, subtool config.Subtool) *cobra.Comm
 	return subtoolCmd
 }
 
+// shortDescription returns the one-line help of a tool command
+func shortDescription(description, fullName string, deprecated *config.Deprecation) string {
+	if description == "" {
+		description = fmt.Sprintf("Execute %s command", fullName)
+	}
+	if deprecated != nil {
+		description = "[deprecated] " + description
+	}
+	return description
+}
+
+// commandAliases returns subtool aliases as command names, with spaces
+// replaced by underscores like subtool names
+func commandAliases(aliases []string) []string {
+	result := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		result = append(result, strings.ReplaceAll(alias, " ", "_"))
+	}
+	return result
+}
+
+// longDescription returns the help text of a tool command
+func longDescription(description, long string) string {
+	if long == "" {
+		return description
+	}
+	if description == "" {
+		return long
+	}
+	return description + "\n\n" + long
+}
+
+// formatExamples indents examples for cobra's Example section
+func formatExamples(examples []string) string {
+	lines := make([]string, 0, len(examples))
+	for _, example := range examples {
+		lines = append(lines, "  "+example)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func addParamFlags(cmd *cobra.Command, params config.Parameters) {
 	for name, param := range params {
 		switch param.Type {
@@ -298,7 +374,7 @@ func addParamFlags(cmd *cobra.Command, params config.Parameters) {
 			cmd.Flags().String(name, "", param.Description)
 		}
 
-		if param.Required {
+		if param.Required && !param.HasFallback() {
 			cmd.MarkFlagRequired(name)
 		}
 	}
@@ -345,20 +421,72 @@ func printSubtools(subtools []tool.Info, level int, parentPath string, verbose b
 		fmt.Printf("%s%s (%s)\n", prefix, subtool.Name, fullPath)
 
 		// If verbose mode, display parameter information
-		if verbose && len(subtool.Params) > 0 {
-			paramIndent := indent + "   " + "  " // Indent for parameters
-			fmt.Printf("%sParameters:\n", paramIndent)
-
-			for name, param := range subtool.Params {
-				required := ""
-				if param.Required {
-					required = " (required)"
-				}
-				fmt.Printf("%s  --%s%s: %s\n", paramIndent, name, required, param.Description)
-			}
+		if verbose {
+			printDescription(subtool, indent+"   "+"  ")
+			printParams(subtool.Params, indent+"   "+"  ")
 		}
 
 		// Display nested subtools
 		printSubtools(subtool.Subtools, level+1, fullPath, verbose)
 	}
 }
+
+// printDescription displays the deprecation notice, aliases, description and
+// examples of a tool at the given indent
+func printDescription(info tool.Info, indent string) {
+	if info.Deprecated != nil {
+		fmt.Printf("%sDEPRECATED: %s\n", indent, info.Deprecated.Notice(info.Name))
+	}
+	if len(info.Aliases) > 0 {
+		fmt.Printf("%sAliases: %s\n", indent, strings.Join(commandAliases(info.Aliases), ", "))
+	}
+	if info.Description != "" {
+		fmt.Printf("%s%s\n", indent, info.Description)
+	}
+	if info.LongDescription != "" {
+		for _, line := range strings.Split(strings.TrimRight(info.LongDescription, "\n"), "\n") {
+			fmt.Printf("%s%s\n", indent, line)
+		}
+	}
+	if len(info.Examples) > 0 {
+		fmt.Printf("%sExamples:\n", indent)
+		for _, example := range info.Examples {
+			fmt.Printf("%s  %s\n", indent, example)
+		}
+	}
+}
+
+// printParams displays parameter information at the given indent
+func printParams(params map[string]config.Parameter, paramIndent string) {
+	if len(params) == 0 {
+		return
+	}
+
+	fmt.Printf("%sParameters:\n", paramIndent)
+	for name, param := range params {
+		required := ""
+		if param.Required {
+			required = " (required)"
+		}
+		fmt.Printf("%s  --%s%s: %s%s\n", paramIndent, name, required, param.Description, paramSources(param))
+	}
+}
+
+// paramSources describes where a parameter value comes from when it is not
+// given on the command line, in order of precedence
+func paramSources(param config.Parameter) string {
+	var sources []string
+	if param.Env != "" {
+		sources = append(sources, "env: "+param.Env)
+	}
+	if param.FromContext != "" {
+		sources = append(sources, "from_context: "+param.FromContext)
+	}
+	if param.Default != "" {
+		sources = append(sources, "default: "+param.Default)
+	}
+	if len(sources) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(sources, ", ") + "]"
+}
@@ -3,13 +3,17 @@ module github.com/takutakahashi/operation-mcp
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/pkg/sftp v1.13.6
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.20.0
+	golang.org/x/term v0.17.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 )
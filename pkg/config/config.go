@@ -4,40 +4,270 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/template/parse"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Actions []Action   `yaml:"actions"`
-	Tools   []Tool     `yaml:"tools"`
-	SSH     *SSHConfig `yaml:"ssh,omitempty"`
+	Version     int                `yaml:"version,omitempty"`
+	Actions     []Action           `yaml:"actions"`
+	Tools       []Tool             `yaml:"tools"`
+	SSH         *SSHConfig         `yaml:"ssh,omitempty"`
+	Targets     map[string]Target  `yaml:"targets,omitempty"`
+	FanOut      *FanOutConfig      `yaml:"fanout,omitempty"`
+	TemplateEnv []string           `yaml:"template_env,omitempty"`
+	Include     []string           `yaml:"include,omitempty"`
+	Definitions *Definitions       `yaml:"definitions,omitempty"`
+	Profiles    map[string]Profile `yaml:"profiles,omitempty"`
+
+	// Files lists every file the configuration was loaded from, starting
+	// with the main config file
+	Files []string `yaml:"-"`
+
+	// warnings holds issues found while decoding, such as unknown keys in
+	// files of an older version
+	warnings []Issue
 }
 
 // Action represents a danger level action configuration
 type Action struct {
-	DangerLevel string `yaml:"danger_level"`
-	Type        string `yaml:"type"`
-	Message     string `yaml:"message"`
-	Timeout     int    `yaml:"timeout"`
+	DangerLevel string   `yaml:"danger_level"`
+	Type        string   `yaml:"type"`
+	Message     string   `yaml:"message"`
+	Timeout     int      `yaml:"timeout"`
+	Pos         Position `yaml:"-"`
+}
+
+// UnmarshalYAML decodes an action and records its position
+func (a *Action) UnmarshalYAML(value *yaml.Node) error {
+	type plain Action
+	if err := value.Decode((*plain)(a)); err != nil {
+		return err
+	}
+	a.Pos = nodePosition(value)
+	return nil
+}
+
+// Position is a location in a configuration file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String formats the position as file:line:column, omitting unknown parts
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	pos := fmt.Sprintf("%d", p.Line)
+	if p.Column > 0 {
+		pos = fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if p.File == "" {
+		return pos
+	}
+	return p.File + ":" + pos
+}
+
+// nodePosition returns the position of a YAML node
+func nodePosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+// listPositions returns the position of each element of the list stored
+// under key in a mapping node. Elements of an arg group each take the position
+// of the group, matching the expansion done by Args.UnmarshalYAML.
+func listPositions(mapping *yaml.Node, key string) []Position {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		var positions []Position
+		for _, item := range mapping.Content[i+1].Content {
+			if item.Kind != yaml.MappingNode {
+				positions = append(positions, nodePosition(item))
+				continue
+			}
+			var group ArgGroup
+			if err := item.Decode(&group); err == nil {
+				for range group.Args {
+					positions = append(positions, nodePosition(item))
+				}
+			}
+		}
+		return positions
+	}
+	return nil
 }
 
 // Tool represents a tool configuration
 type Tool struct {
-	Name     string     `yaml:"name"`
-	Command  []string   `yaml:"command"`
-	Params   Parameters `yaml:"params"`
-	Subtools []Subtool  `yaml:"subtools"`
+	Name            string       `yaml:"name"`
+	Description     string       `yaml:"description,omitempty"`
+	LongDescription string       `yaml:"long_description,omitempty"`
+	Examples        []string     `yaml:"examples,omitempty"`
+	Aliases         []string     `yaml:"aliases,omitempty"`
+	Deprecated      *Deprecation `yaml:"deprecated,omitempty"`
+	Target          string       `yaml:"target,omitempty"`
+	Targets         []string     `yaml:"targets,omitempty"`
+	Rollout         *Rollout     `yaml:"rollout,omitempty"`
+	Workdir         string       `yaml:"workdir,omitempty"`
+	Env             Env          `yaml:"env,omitempty"`
+	Sudo            *Sudo        `yaml:"sudo,omitempty"`
+	Command         []string     `yaml:"command"`
+	Params          Parameters   `yaml:"params"`
+	Subtools        []Subtool    `yaml:"subtools"`
+	Override        bool         `yaml:"override,omitempty"`
+	Pos             Position     `yaml:"-"`
+
+	// commandPos holds the position of each element of Command
+	commandPos []Position
+}
+
+// UnmarshalYAML decodes a tool and records its position
+func (t *Tool) UnmarshalYAML(value *yaml.Node) error {
+	type plain Tool
+	if err := value.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.Pos = nodePosition(value)
+	t.commandPos = listPositions(value, "command")
+	return nil
 }
 
 // Subtool represents a subtool configuration
 type Subtool struct {
-	Name        string     `yaml:"name"`
-	Args        []string   `yaml:"args"`
-	Params      Parameters `yaml:"params"`
-	DangerLevel string     `yaml:"danger_level"`
-	Subtools    []Subtool  `yaml:"subtools"`
+	Name            string       `yaml:"name"`
+	Description     string       `yaml:"description,omitempty"`
+	LongDescription string       `yaml:"long_description,omitempty"`
+	Examples        []string     `yaml:"examples,omitempty"`
+	Aliases         []string     `yaml:"aliases,omitempty"`
+	Deprecated      *Deprecation `yaml:"deprecated,omitempty"`
+	Target          string       `yaml:"target,omitempty"`
+	Targets         []string     `yaml:"targets,omitempty"`
+	Rollout         *Rollout     `yaml:"rollout,omitempty"`
+	Workdir         string       `yaml:"workdir,omitempty"`
+	Env             Env          `yaml:"env,omitempty"`
+	Sudo            *Sudo        `yaml:"sudo,omitempty"`
+	Transfer        *Transfer    `yaml:"transfer,omitempty"`
+	TTY             bool         `yaml:"tty,omitempty"`
+	Args            Args         `yaml:"args"`
+	Params          Parameters   `yaml:"params"`
+	DangerLevel     string       `yaml:"danger_level"`
+	Subtools        []Subtool    `yaml:"subtools"`
+	Pos             Position     `yaml:"-"`
+
+	// argPos holds the position of each element of Args
+	argPos []Position
+}
+
+// Deprecation marks a tool or subtool as deprecated. Deprecated tools still
+// run, but print a warning.
+type Deprecation struct {
+	Message     string `yaml:"message,omitempty"`
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
+// Notice returns the warning shown when the named tool is used
+func (d *Deprecation) Notice(name string) string {
+	notice := name + " is deprecated"
+	if d.Message != "" {
+		notice += ": " + d.Message
+	}
+	if d.Replacement != "" {
+		notice += fmt.Sprintf(" (use %s instead)", d.Replacement)
+	}
+	return notice
+}
+
+// UnmarshalYAML decodes a subtool and records its position
+func (s *Subtool) UnmarshalYAML(value *yaml.Node) error {
+	type plain Subtool
+	if err := value.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.Pos = nodePosition(value)
+	s.argPos = listPositions(value, "args")
+	return nil
+}
+
+// Args is a list of argument templates for a subtool. In YAML, an element may
+// also be an arg group whose arguments are only included when its condition
+// is true:
+//
+//	args:
+//	  - logs
+//	  - "{{.pod}}"
+//	  - when: .container
+//	    args: ["-c", "{{.container}}"]
+type Args []string
+
+// ArgGroup is a group of arguments included only when When evaluates to true
+type ArgGroup struct {
+	When string   `yaml:"when"`
+	Args []string `yaml:"args"`
+}
+
+// OmitFunc is the name of the template function that drops an argument
+const OmitFunc = "omit"
+
+// UnmarshalYAML decodes plain string arguments and arg groups. Each argument
+// of a group is expanded to a template that is omitted when the condition is
+// false, so the result is still a flat list of templates.
+func (a *Args) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: args must be a list", value.Line)
+	}
+
+	args := make(Args, 0, len(value.Content))
+	for _, item := range value.Content {
+		if item.Kind == yaml.ScalarNode {
+			args = append(args, item.Value)
+			continue
+		}
+
+		var group ArgGroup
+		if err := item.Decode(&group); err != nil {
+			return err
+		}
+		if group.When == "" {
+			return fmt.Errorf("line %d: arg group missing when", item.Line)
+		}
+		if err := checkWhen(group.When); err != nil {
+			return fmt.Errorf("line %d: invalid when %q in arg group: %v", item.Line, group.When, err)
+		}
+		for _, arg := range group.Args {
+			args = append(args, fmt.Sprintf("{{if %s}}%s{{else}}{{%s}}{{end}}", group.When, arg, OmitFunc))
+		}
+	}
+
+	*a = args
+	return nil
+}
+
+// checkWhen checks that the condition of an arg group is a single template
+// pipeline, such as ".container" or "eq .mode \"fast\"", before it is pasted
+// into the templates of the group's arguments
+func checkWhen(when string) error {
+	if strings.Contains(when, "{{") || strings.Contains(when, "}}") {
+		return fmt.Errorf("must not contain {{ or }}")
+	}
+
+	tree := parse.New("when")
+	if _, err := tree.Parse("{{"+when+"}}", "", "", make(map[string]*parse.Tree), templateFuncs()); err != nil {
+		return err
+	}
+	if len(tree.Root.Nodes) != 1 || tree.Root.Nodes[0].Type() != parse.NodeAction {
+		return fmt.Errorf("must be a single pipeline")
+	}
+	return nil
 }
 
 // Parameter represents a parameter configuration
@@ -46,6 +276,22 @@ type Parameter struct {
 	Type        string       `yaml:"type"`
 	Required    bool         `yaml:"required"`
 	Validate    []Validation `yaml:"validate"`
+	Default     string       `yaml:"default"`
+	Env         string       `yaml:"env"`
+	FromContext string       `yaml:"from_context"`
+	Pos         Position     `yaml:"-"`
+}
+
+// Context sources that can be used in Parameter.FromContext
+const (
+	ContextKubeContext   = "kube_context"
+	ContextKubeNamespace = "kube_namespace"
+)
+
+// HasFallback reports whether the parameter can be filled without the caller
+// providing a value
+func (p Parameter) HasFallback() bool {
+	return p.Default != "" || p.Env != "" || p.FromContext != ""
 }
 
 // Validation represents validation rules for parameters
@@ -57,37 +303,84 @@ type Validation struct {
 // Parameters is a map of parameter name to Parameter
 type Parameters map[string]Parameter
 
+// UnmarshalYAML decodes parameters and records the position of each name
+func (p *Parameters) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: params must be a mapping", value.Line)
+	}
+
+	params := make(Parameters, len(value.Content)/2)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, item := value.Content[i], value.Content[i+1]
+
+		var param Parameter
+		if err := item.Decode(&param); err != nil {
+			return err
+		}
+		param.Pos = nodePosition(key)
+		params[key.Value] = param
+	}
+
+	*p = params
+	return nil
+}
+
 // SSHConfig represents SSH connection configuration
 type SSHConfig struct {
-	Host        string `yaml:"host,omitempty"`
-	Port        int    `yaml:"port,omitempty"`
-	User        string `yaml:"user,omitempty"`
-	Password    string `yaml:"password,omitempty"`
-	KeyPath     string `yaml:"key,omitempty"`
-	VerifyHost  *bool  `yaml:"verify_host,omitempty"`
-	HostKeyPath string `yaml:"host_key_path,omitempty"`
-	Timeout     int    `yaml:"timeout,omitempty"` // in seconds
+	Host               string `yaml:"host,omitempty"`
+	Port               int    `yaml:"port,omitempty"`
+	User               string `yaml:"user,omitempty"`
+	Password           string `yaml:"password,omitempty"`
+	KeyPath            string `yaml:"key,omitempty"`
+	KeyPassphrase      string `yaml:"key_passphrase,omitempty"`
+	Certificate        string `yaml:"certificate,omitempty"`
+	Agent              *bool  `yaml:"agent,omitempty"`
+	VerifyHost         *bool  `yaml:"verify_host,omitempty"`
+	HostKeyPath        string `yaml:"host_key_path,omitempty"`
+	HostKeyPolicy      string `yaml:"host_key_policy,omitempty"`
+	HostKeyFingerprint string `yaml:"host_key_fingerprint,omitempty"`
+	Timeout            int    `yaml:"timeout,omitempty"` // in seconds
+
+	// ProxyJump lists the jump hosts to connect through, in order. Each hop
+	// has its own address and authentication.
+	ProxyJump []SSHConfig `yaml:"proxy_jump,omitempty"`
 }
 
-// LoadConfig loads the configuration from a file
+// LoadConfig loads the configuration from a file. The format of the file is
+// chosen by its extension.
 func LoadConfig(configPath string) (*Config, error) {
+	return LoadConfigFormat(configPath, "")
+}
+
+// LoadConfigFormat loads the configuration from a file in the given format.
+// An empty format chooses the format by extension. Included files are always
+// read according to their extension.
+func LoadConfigFormat(configPath string, format Format) (*Config, error) {
+	if err := validFormat(format); err != nil {
+		return nil, err
+	}
+
 	// If configPath is not provided, look for default locations
 	if configPath == "" {
 		// Check for config in home directory
 		home, err := os.UserHomeDir()
 		if err == nil {
-			homeConfig := filepath.Join(home, ".operations", "config.yaml")
-			if _, err := os.Stat(homeConfig); err == nil {
-				configPath = homeConfig
+			for _, name := range []string{"config.yaml", "config.json", "config.toml"} {
+				homeConfig := filepath.Join(home, ".operations", name)
+				if _, err := os.Stat(homeConfig); err == nil {
+					configPath = homeConfig
+					break
+				}
 			}
 		}
 
 		// Check for config in current directory
 		if configPath == "" {
-			if _, err := os.Stat("operations.yaml"); err == nil {
-				configPath = "operations.yaml"
-			} else if _, err := os.Stat("config.yaml"); err == nil {
-				configPath = "config.yaml"
+			for _, name := range []string{"operations.yaml", "operations.json", "operations.toml", "config.yaml"} {
+				if _, err := os.Stat(name); err == nil {
+					configPath = name
+					break
+				}
 			}
 		}
 
@@ -97,93 +390,289 @@ func LoadConfig(configPath string) (*Config, error) {
 		}
 	}
 
+	// Parse the config file together with the files it includes and the
+	// files in ~/.operations/conf.d, then decode them with the definitions
+	// of all of them
+	l := newLoader(format)
+	main, err := l.parse(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err := ConfDir(); err == nil {
+		files, err := l.parseConfDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		main.children = append(main.children, files...)
+	}
+	if len(l.errs) > 0 {
+		return nil, fmt.Errorf("error resolving references: %w", l.errs)
+	}
+
+	config, err := l.build(main)
+	if err != nil {
+		return nil, err
+	}
+	config.Files = l.files
+
+	return config, nil
+}
+
+// parseConfigFile reads a single config file into a document node and checks
+// its format version
+func parseConfigFile(configPath string, format Format) (*yaml.Node, []Issue, error) {
 	// Read the config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+		return nil, nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Parse the file into a node tree first, so that decoding records the
+	// position of every element
+	root, err := parseDocument(data, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing config file: %w", decodeErrors(configPath, err))
+	}
+
+	// Check the format version and reject unknown keys in current files.
+	// This runs before references are resolved, so each key is reported once.
+	var warnings []Issue
+	if len(root.Content) > 0 {
+		warnings, err = checkVersion(root.Content[0], configPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+	}
+	return root, warnings, nil
+}
+
+// decodeConfigFile resolves the references of a parsed config file, looking
+// up definitions of other files in shared, and decodes it without its includes
+func decodeConfigFile(configPath string, root *yaml.Node, warnings []Issue, shared *yaml.Node) (*Config, error) {
+	// Replace $ref mappings with the definitions they reference
+	if err := resolveRefs(root, configPath, shared); err != nil {
+		return nil, fmt.Errorf("error resolving references: %w", err)
 	}
 
-	// Parse the YAML
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if len(root.Content) > 0 {
+		if err := root.Decode(&config); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", decodeErrors(configPath, err))
+		}
 	}
+	config.setFile(configPath)
+	config.warnings = warnings
 
 	return &config, nil
 }
 
-// Validate validates the configuration
+// setFile records the file every action, target, tool, subtool and parameter was loaded from
+func (c *Config) setFile(file string) {
+	for i := range c.Actions {
+		c.Actions[i].Pos.File = file
+	}
+	for _, profile := range c.Profiles {
+		for i := range profile.Actions {
+			profile.Actions[i].Pos.File = file
+		}
+	}
+	for name, target := range c.Targets {
+		target.Pos.File = file
+		c.Targets[name] = target
+	}
+	for i := range c.Tools {
+		c.Tools[i].Pos.File = file
+		setPositionsFile(c.Tools[i].commandPos, file)
+		setParamsFile(c.Tools[i].Params, file)
+		setSubtoolsFile(c.Tools[i].Subtools, file)
+	}
+}
+
+// setSubtoolsFile records the file for subtools recursively
+func setSubtoolsFile(subtools []Subtool, file string) {
+	for i := range subtools {
+		subtools[i].Pos.File = file
+		setPositionsFile(subtools[i].argPos, file)
+		setParamsFile(subtools[i].Params, file)
+		setSubtoolsFile(subtools[i].Subtools, file)
+	}
+}
+
+// setPositionsFile records the file for a list of positions
+func setPositionsFile(positions []Position, file string) {
+	for i := range positions {
+		positions[i].File = file
+	}
+}
+
+// setParamsFile records the file for parameters
+func setParamsFile(params Parameters, file string) {
+	for name, param := range params {
+		param.Pos.File = file
+		params[name] = param
+	}
+}
+
+// Validate validates the configuration and returns every error found as
+// ValidationErrors
 func (c *Config) Validate() error {
+	var errs ValidationErrors
+	for _, issue := range c.Lint() {
+		if !issue.Warning {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Lint returns every error and warning found in the configuration, ordered
+// by position
+func (c *Config) Lint() []Issue {
+	issues := append([]Issue(nil), c.warnings...)
+
 	// Validate actions
 	for _, action := range c.Actions {
-		if action.DangerLevel == "" {
-			return fmt.Errorf("action missing danger_level")
-		}
-		if action.Type == "" {
-			return fmt.Errorf("action missing type")
-		}
-		if action.Type != "confirm" && action.Type != "timeout" && action.Type != "force" {
-			return fmt.Errorf("invalid action type: %s", action.Type)
-		}
-		if action.Type == "timeout" && action.Timeout <= 0 {
-			return fmt.Errorf("timeout action requires positive timeout value")
+		issues = append(issues, validateAction(action)...)
+	}
+
+	// Validate actions overridden by profiles and the danger levels they set
+	for _, name := range sortedProfileNames(c.Profiles) {
+		for _, action := range c.Profiles[name].Actions {
+			issues = append(issues, validateAction(action)...)
 		}
+		issues = append(issues, c.validateProfile(name)...)
 	}
 
 	// Validate tools
+	toolNames := make(map[string]string)
 	for _, tool := range c.Tools {
 		if tool.Name == "" {
-			return fmt.Errorf("tool missing name")
+			issues = append(issues, newIssue(tool.Pos, "tool missing name"))
+		}
+		issues = append(issues, validateNames(toolNames, "tool", tool.Name, tool.Aliases, tool.Pos)...)
+		for _, alias := range tool.Aliases {
+			if strings.Contains(alias, "_") {
+				issues = append(issues, newIssue(tool.Pos, "alias %s of tool %s must not contain _", alias, tool.Name))
+			}
 		}
-		if len(tool.Command) == 0 {
-			return fmt.Errorf("tool %s missing command", tool.Name)
+		if len(tool.Command) == 0 && !transfersOnly(tool) {
+			issues = append(issues, newIssue(tool.Pos, "tool %s missing command", tool.Name))
 		}
 
 		// Validate tool parameters
-		for name, param := range tool.Params {
-			if name == "" {
-				return fmt.Errorf("tool %s has parameter with empty name", tool.Name)
-			}
-			if param.Type == "" {
-				return fmt.Errorf("parameter %s in tool %s missing type", name, tool.Name)
-			}
-		}
+		issues = append(issues, validateParams(tool.Params, "tool "+tool.Name, tool.Pos)...)
 
 		// Validate subtools
+		subtoolNames := make(map[string]string)
 		for _, subtool := range tool.Subtools {
-			if err := validateSubtool(subtool, tool.Name); err != nil {
-				return err
-			}
+			issues = append(issues, validateNames(subtoolNames, "subtool", subtool.Name, subtool.Aliases, subtool.Pos)...)
+			issues = append(issues, validateSubtool(subtool, tool.Name)...)
 		}
 	}
 
-	return nil
+	// Validate targets and the targets tools run on
+	issues = append(issues, c.validateTargets()...)
+	issues = append(issues, c.validateRollouts()...)
+	issues = append(issues, c.validateEnvironments()...)
+	issues = append(issues, c.validateTransfers()...)
+
+	// Validate templates in commands and args
+	issues = append(issues, c.CheckTemplates()...)
+
+	sortIssues(issues)
+	return issues
+}
+
+// validateAction validates an action configuration
+func validateAction(action Action) []Issue {
+	var issues []Issue
+	if action.DangerLevel == "" {
+		issues = append(issues, newIssue(action.Pos, "action missing danger_level"))
+	}
+	if action.Type == "" {
+		issues = append(issues, newIssue(action.Pos, "action missing type"))
+	} else if action.Type != "confirm" && action.Type != "timeout" && action.Type != "force" {
+		issues = append(issues, newIssue(action.Pos, "invalid action type: %s", action.Type))
+	}
+	if action.Type == "timeout" && action.Timeout <= 0 {
+		issues = append(issues, newIssue(action.Pos, "timeout action requires positive timeout value"))
+	}
+	return issues
 }
 
 // validateSubtool validates a subtool configuration
-func validateSubtool(subtool Subtool, parentName string) error {
+func validateSubtool(subtool Subtool, parentName string) []Issue {
+	var issues []Issue
 	if subtool.Name == "" {
-		return fmt.Errorf("subtool of %s missing name", parentName)
+		issues = append(issues, newIssue(subtool.Pos, "subtool of %s missing name", parentName))
 	}
 
 	fullName := parentName + "_" + subtool.Name
 
 	// Validate subtool parameters
-	for name, param := range subtool.Params {
-		if name == "" {
-			return fmt.Errorf("subtool %s has parameter with empty name", fullName)
+	issues = append(issues, validateParams(subtool.Params, "subtool "+fullName, subtool.Pos)...)
+
+	// Validate nested subtools
+	names := make(map[string]string)
+	for _, nestedSubtool := range subtool.Subtools {
+		issues = append(issues, validateNames(names, "subtool", nestedSubtool.Name, nestedSubtool.Aliases, nestedSubtool.Pos)...)
+		issues = append(issues, validateSubtool(nestedSubtool, fullName)...)
+	}
+
+	return issues
+}
+
+// validateNames checks that the name and aliases of a tool or subtool do not
+// clash with those of its siblings. seen maps each name already used at this
+// level to the tool or subtool using it.
+func validateNames(seen map[string]string, kind, name string, aliases []string, pos Position) []Issue {
+	var issues []Issue
+	for i, candidate := range append([]string{name}, aliases...) {
+		key := strings.ReplaceAll(candidate, " ", "_")
+		if key == "" {
+			continue
 		}
-		if param.Type == "" {
-			return fmt.Errorf("parameter %s in subtool %s missing type", name, fullName)
+		if owner, exists := seen[key]; exists {
+			if i == 0 {
+				issues = append(issues, newIssue(pos, "duplicate %s name %s (also used by %s)", kind, candidate, owner))
+			} else {
+				issues = append(issues, newIssue(pos, "alias %s of %s %s is already used by %s", candidate, kind, name, owner))
+			}
+			continue
 		}
+		seen[key] = name
 	}
+	return issues
+}
 
-	// Validate nested subtools
-	for _, nestedSubtool := range subtool.Subtools {
-		if err := validateSubtool(nestedSubtool, fullName); err != nil {
-			return err
+// validateParams validates the parameters declared by a tool or subtool
+func validateParams(params Parameters, owner string, ownerPos Position) []Issue {
+	var issues []Issue
+	for _, name := range sortedParamNames(params) {
+		param := params[name]
+		if name == "" {
+			issues = append(issues, newIssue(ownerPos, "%s has parameter with empty name", owner))
+			continue
+		}
+		if param.Type == "" {
+			issues = append(issues, newIssue(param.Pos, "parameter %s in %s missing type", name, owner))
+		}
+		if err := validateFromContext(param.FromContext); err != nil {
+			issues = append(issues, newIssue(param.Pos, "parameter %s in %s: %v", name, owner, err))
 		}
 	}
+	return issues
+}
 
-	return nil
+// validateFromContext checks that a from_context value names a known source
+func validateFromContext(source string) error {
+	switch source {
+	case "", ContextKubeContext, ContextKubeNamespace:
+		return nil
+	default:
+		return fmt.Errorf("unknown from_context source: %s", source)
+	}
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -236,3 +239,222 @@ func TestConfigValidate(t *testing.T) {
 		t.Errorf("Validation should fail for config with missing command")
 	}
 }
+
+func TestConfigValidateFromContext(t *testing.T) {
+	cfg := &Config{
+		Tools: []Tool{
+			{
+				Name:    "kubectl",
+				Command: []string{"kubectl"},
+				Params: map[string]Parameter{
+					"namespace": {
+						Type:        "string",
+						Required:    true,
+						Default:     "default",
+						Env:         "KUBE_NAMESPACE",
+						FromContext: ContextKubeNamespace,
+					},
+				},
+			},
+		},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validation failed for valid from_context: %v", err)
+	}
+
+	cfg.Tools[0].Params["namespace"] = Parameter{Type: "string", FromContext: "unknown"}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Validation should fail for unknown from_context source")
+	}
+}
+
+func TestLoadConfigArgGroups(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+tools:
+  - name: kubectl
+    command: [kubectl]
+    subtools:
+      - name: logs
+        params:
+          container:
+            type: string
+        args:
+          - logs
+          - when: .container
+            args: ["-c", "{{.container}}"]
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	args := cfg.Tools[0].Subtools[0].Args
+	if len(args) != 3 {
+		t.Fatalf("Expected 3 args, got %d: %v", len(args), args)
+	}
+	if args[0] != "logs" {
+		t.Errorf("Expected first arg 'logs', got '%s'", args[0])
+	}
+	expected := "{{if .container}}-c{{else}}{{omit}}{{end}}"
+	if args[1] != expected {
+		t.Errorf("Expected arg %q, got %q", expected, args[1])
+	}
+
+	// Arg groups require a condition
+	invalidContent := `
+tools:
+  - name: kubectl
+    command: [kubectl]
+    subtools:
+      - name: logs
+        args:
+          - args: ["-c", "{{.container}}"]
+`
+	if err := os.WriteFile(configPath, []byte(invalidContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Errorf("LoadConfig should fail for arg group without when")
+	}
+
+	// The condition must be a single pipeline, reported at the group's line
+	for _, when := range []string{`.container}}{{.other`, `(.container`, `"unterminated`, `end`, `uper .container`} {
+		invalidContent := fmt.Sprintf(`
+tools:
+  - name: kubectl
+    command: [kubectl]
+    subtools:
+      - name: logs
+        args:
+          - when: %q
+            args: ["-c", "{{.container}}"]
+`, when)
+		if err := os.WriteFile(configPath, []byte(invalidContent), 0644); err != nil {
+			t.Fatalf("Failed to write test config file: %v", err)
+		}
+		_, err := LoadConfig(configPath)
+		if err == nil || !strings.Contains(err.Error(), ":8:") || !strings.Contains(err.Error(), "invalid when") {
+			t.Errorf("Expected invalid when error at line 8 for %q, got %v", when, err)
+		}
+	}
+}
+
+func TestConfigValidateCollectsErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `actions:
+  - type: invalid
+tools:
+  - name: kubectl
+    params:
+      namespace:
+        description: missing type
+    subtools:
+      - args: ["get", "pod"]
+        subtools:
+          - name: nested
+            params:
+              pod:
+                description: missing type
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	err = cfg.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T: %v", err, err)
+	}
+
+	expected := []string{
+		configPath + ":2:5: action missing danger_level",
+		configPath + ":2:5: invalid action type: invalid",
+		configPath + ":4:5: tool kubectl missing command",
+		configPath + ":6:7: parameter namespace in tool kubectl missing type",
+		configPath + ":9:9: subtool of kubectl missing name",
+		configPath + ":13:15: parameter pod in subtool kubectl__nested missing type",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, want := range expected {
+		if errs[i].Error() != want {
+			t.Errorf("Expected error %q, got %q", want, errs[i].Error())
+		}
+	}
+}
+
+func TestLoadConfigSyntaxErrorPosition(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("tools\n- name: kubectl\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %T: %v", err, err)
+	}
+	if errs[0].Pos.File != configPath || errs[0].Pos.Line != 2 {
+		t.Errorf("Expected error at %s:2, got %v", configPath, errs[0].Pos)
+	}
+}
+
+func TestConfigValidateAliases(t *testing.T) {
+	cfg := &Config{
+		Tools: []Tool{
+			{Name: "kubectl", Aliases: []string{"k", "kube_ctl"}, Command: []string{"kubectl"},
+				Subtools: []Subtool{
+					{Name: "get pods", Aliases: []string{"ls"}, Args: []string{"get", "pods"}},
+					{Name: "list", Aliases: []string{"get pods"}, Args: []string{"get", "pods"}},
+				},
+			},
+			{Name: "k", Command: []string{"k9s"}},
+		},
+	}
+
+	err := cfg.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T: %v", err, err)
+	}
+
+	expected := []string{
+		"duplicate tool name k (also used by kubectl)",
+		"alias kube_ctl of tool kubectl must not contain _",
+		"alias get pods of subtool list is already used by get pods",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for _, want := range expected {
+		found := false
+		for _, issue := range errs {
+			if issue.Message == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected error %q, got %v", want, errs)
+		}
+	}
+}
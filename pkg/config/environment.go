@@ -0,0 +1,66 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Env holds the environment variables set for a tool. Values may use
+// templates like args.
+type Env map[string]string
+
+// Names returns the variable names in sorted order
+func (e Env) Names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sudo runs a tool as another user with sudo
+type Sudo struct {
+	// User is the user to run as (default: root)
+	User string `yaml:"user,omitempty"`
+	// NonInteractive makes sudo fail instead of asking for a password
+	NonInteractive bool `yaml:"non_interactive,omitempty"`
+}
+
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnvironments validates the environment variables of tools and
+// subtools
+func (c *Config) validateEnvironments() []Issue {
+	var issues []Issue
+	for _, tool := range c.Tools {
+		issues = append(issues, validateEnv(tool.Env, "tool "+tool.Name, tool.Pos)...)
+		issues = append(issues, validateSubtoolEnvs(tool.Subtools, tool.Name)...)
+	}
+	return issues
+}
+
+// validateSubtoolEnvs validates the environment variables of subtools
+// recursively
+func validateSubtoolEnvs(subtools []Subtool, parentName string) []Issue {
+	var issues []Issue
+	for _, subtool := range subtools {
+		fullName := parentName + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+		issues = append(issues, validateEnv(subtool.Env, "subtool "+fullName, subtool.Pos)...)
+		issues = append(issues, validateSubtoolEnvs(subtool.Subtools, fullName)...)
+	}
+	return issues
+}
+
+// validateEnv checks the names of the environment variables of one tool
+func validateEnv(env Env, owner string, pos Position) []Issue {
+	var issues []Issue
+	for _, name := range env.Names() {
+		if !envNamePattern.MatchString(name) {
+			issues = append(issues, newIssue(pos, "invalid environment variable name %q in %s", name, owner))
+		}
+	}
+	return issues
+}
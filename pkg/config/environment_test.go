@@ -0,0 +1,74 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `version: 2
+tools:
+  - name: rails
+    command: [bin/rails]
+    workdir: /srv/{{.app}}
+    env:
+      RAILS_ENV: production
+    sudo:
+      user: deploy
+      non_interactive: true
+    params:
+      app:
+        type: string
+    subtools:
+      - name: migrate
+        args: [db:migrate]
+        env:
+          VERSION: "{{.version}}"
+          1BAD: x
+        params:
+          version:
+            type: string
+      - name: console
+        args: [console]
+        env:
+          TOKEN: "{{.token}}"
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tool := cfg.Tools[0]
+	if tool.Workdir != "/srv/{{.app}}" || tool.Env["RAILS_ENV"] != "production" {
+		t.Errorf("Unexpected workdir %q or env %v", tool.Workdir, tool.Env)
+	}
+	if tool.Sudo == nil || tool.Sudo.User != "deploy" || !tool.Sudo.NonInteractive {
+		t.Errorf("Unexpected sudo: %+v", tool.Sudo)
+	}
+
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation errors")
+	}
+	expected := []string{
+		`invalid environment variable name "1BAD" in subtool rails_migrate`,
+		`template "{{.token}}" in rails_console references undeclared parameter token`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%v", want, err)
+		}
+	}
+
+	// Parameters used only in workdir and env count as used
+	for _, issue := range cfg.Lint() {
+		if strings.Contains(issue.Message, "never used") {
+			t.Errorf("Unexpected warning: %s", issue.Message)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Issue is a problem found in a configuration file
+type Issue struct {
+	Pos     Position
+	Message string
+	// Warning is true for issues that do not make the configuration invalid
+	Warning bool
+}
+
+// newIssue creates an error issue at the given position
+func newIssue(pos Position, format string, args ...interface{}) Issue {
+	return Issue{Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface
+func (i Issue) Error() string {
+	if pos := i.Pos.String(); pos != "" {
+		return fmt.Sprintf("%s: %s", pos, i.Message)
+	}
+	return i.Message
+}
+
+// Severity returns "error" or "warning"
+func (i Issue) Severity() string {
+	if i.Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// ValidationErrors is a list of errors found in a configuration file
+type ValidationErrors []Issue
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, issue := range e {
+		messages[i] = issue.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// sortIssues orders issues by file and position. Issues without a position
+// keep their relative order.
+func sortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].Pos, issues[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// yamlLinePattern matches the line prefix of yaml.v3 error messages
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// decodeErrors converts a YAML parsing or decoding error into ValidationErrors
+// carrying the file and line of each problem
+func decodeErrors(file string, err error) ValidationErrors {
+	var messages []string
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	errs := make(ValidationErrors, 0, len(messages))
+	for _, message := range messages {
+		pos := Position{File: file}
+		if m := yamlLinePattern.FindStringSubmatch(message); m != nil {
+			pos.Line, _ = strconv.Atoi(m[1])
+			message = m[2]
+		}
+		errs = append(errs, Issue{Pos: pos, Message: message})
+	}
+	return errs
+}
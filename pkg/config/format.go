@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a config file
+type Format string
+
+// Supported config file formats
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// FormatFromPath returns the format of a config file based on its
+// extension. Files with an unknown extension are read as YAML.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// validFormat checks that a format is supported. The empty format selects
+// the format by extension.
+func validFormat(format Format) error {
+	switch format {
+	case "", FormatYAML, FormatJSON, FormatTOML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported config format: %s (expected yaml, json or toml)", format)
+	}
+}
+
+// parseDocument parses a config file into a YAML document node, so that
+// every format shares the same decoding, reference resolution and checks
+func parseDocument(data []byte, format Format) (*yaml.Node, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatTOML:
+		return parseTOML(data)
+	default:
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		return &root, nil
+	}
+}
+
+// parseJSON parses a JSON document, recording the line and column of every
+// value
+func parseJSON(data []byte) (*yaml.Node, error) {
+	p := &jsonParser{data: data, decoder: json.NewDecoder(bytes.NewReader(data))}
+	p.decoder.UseNumber()
+
+	node, err := p.value()
+	if err == io.EOF {
+		// An empty file is an empty document, as with YAML
+		return &yaml.Node{Kind: yaml.DocumentNode}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("line %d: unexpected data after the top-level value", p.line(p.start()))
+	}
+
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}, nil
+}
+
+// jsonParser builds yaml nodes from the tokens of a JSON document
+type jsonParser struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+// value reads the next JSON value
+func (p *jsonParser) value() (*yaml.Node, error) {
+	offset := p.start()
+	token, err := p.decoder.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("line %d: %w", p.line(offset), err)
+	}
+
+	node := &yaml.Node{Line: p.line(offset), Column: p.column(offset)}
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			node.Kind = yaml.MappingNode
+			node.Tag = "!!map"
+			for p.decoder.More() {
+				keyOffset := p.start()
+				key, err := p.decoder.Token()
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", p.line(keyOffset), err)
+				}
+				keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string),
+					Line: p.line(keyOffset), Column: p.column(keyOffset)}
+				valueNode, err := p.value()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				node.Content = append(node.Content, keyNode, valueNode)
+			}
+		case '[':
+			node.Kind = yaml.SequenceNode
+			node.Tag = "!!seq"
+			for p.decoder.More() {
+				item, err := p.value()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				node.Content = append(node.Content, item)
+			}
+		}
+		// Consume the closing delimiter
+		if _, err := p.decoder.Token(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line(p.start()), unexpectedEOF(err))
+		}
+	case string:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!str", token
+	case json.Number:
+		node.Kind, node.Value = yaml.ScalarNode, token.String()
+		if _, err := strconv.ParseInt(token.String(), 10, 64); err == nil {
+			node.Tag = "!!int"
+		} else {
+			node.Tag = "!!float"
+		}
+	case bool:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!bool", strconv.FormatBool(token)
+	case nil:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!null", "null"
+	}
+	return node, nil
+}
+
+// start returns the offset of the next token, skipping the whitespace and
+// separators the decoder has not consumed yet
+func (p *jsonParser) start() int {
+	offset := int(p.decoder.InputOffset())
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// line returns the 1-based line of an offset
+func (p *jsonParser) line(offset int) int {
+	return bytes.Count(p.data[:offset], []byte("\n")) + 1
+}
+
+// column returns the 1-based column of an offset
+func (p *jsonParser) column(offset int) int {
+	return offset - bytes.LastIndexByte(p.data[:offset], '\n')
+}
+
+// unexpectedEOF turns an end of input inside a value into an error
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// parseTOML parses a TOML document. TOML does not report positions, so the
+// nodes have none; keys keep the order of the file.
+func parseTOML(data []byte) (*yaml.Node, error) {
+	var value map[string]interface{}
+	meta, err := toml.Decode(string(data), &value)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(map[string]int)
+	for i, key := range meta.Keys() {
+		if _, exists := order[key.String()]; !exists {
+			order[key.String()] = i
+		}
+	}
+
+	node := tomlNode(value, "", order)
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}, nil
+}
+
+// tomlNode converts a decoded TOML value to a yaml node. order gives the
+// position of each dotted key in the file.
+func tomlNode(value interface{}, path string, order map[string]int) *yaml.Node {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return order[tomlKey(path, keys[i])] < order[tomlKey(path, keys[j])]
+		})
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				tomlNode(value[key], tomlKey(path, key), order))
+		}
+		return node
+	case []map[string]interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			node.Content = append(node.Content, tomlNode(item, path, order))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			node.Content = append(node.Content, tomlNode(item, path, order))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(value, 10)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(value, 'g', -1, 64)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.Format(time.RFC3339)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(value)}
+	}
+}
+
+// tomlKey returns the dotted key of a child of path, as used by toml.Key
+func tomlKey(path, key string) string {
+	child := toml.Key{key}.String()
+	if path == "" {
+		return child
+	}
+	return path + "." + child
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `version: 2
+actions:
+  - danger_level: high
+    type: confirm
+    message: "Proceed?"
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+        required: true
+    subtools:
+      - name: delete
+        args: [delete, "{{.namespace}}", {when: .namespace, args: ["--wait"]}]
+        danger_level: high
+`,
+		"config.json": `{
+	"version": 2,
+	"actions": [
+		{"danger_level": "high", "type": "confirm", "message": "Proceed?"}
+	],
+	"tools": [
+		{
+			"name": "kubectl",
+			"command": ["kubectl"],
+			"params": {"namespace": {"type": "string", "required": true}},
+			"subtools": [
+				{"name": "delete", "args": ["delete", "{{.namespace}}", {"when": ".namespace", "args": ["--wait"]}], "danger_level": "high"}
+			]
+		}
+	]
+}
+`,
+		"config.toml": `version = 2
+
+[[actions]]
+danger_level = "high"
+type = "confirm"
+message = "Proceed?"
+
+[[tools]]
+name = "kubectl"
+command = ["kubectl"]
+
+[tools.params.namespace]
+type = "string"
+required = true
+
+[[tools.subtools]]
+name = "delete"
+args = ["delete", "{{.namespace}}", { when = ".namespace", args = ["--wait"] }]
+danger_level = "high"
+`,
+		// A TOML file with a misleading extension, read with an explicit format
+		"config.conf": `version = 2
+
+[[tools]]
+name = "echo"
+command = ["echo"]
+`,
+	})
+
+	yamlConfig, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed for YAML: %v", err)
+	}
+
+	for _, name := range []string{"config.json", "config.toml"} {
+		cfg, err := LoadConfig(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("LoadConfig failed for %s: %v", name, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate failed for %s: %v", name, err)
+		}
+		if cfg.Actions[0].Message != yamlConfig.Actions[0].Message {
+			t.Errorf("Expected action message %q in %s, got %q", yamlConfig.Actions[0].Message, name, cfg.Actions[0].Message)
+		}
+		if !cfg.Tools[0].Params["namespace"].Required {
+			t.Errorf("Expected namespace parameter in %s to be required", name)
+		}
+		subtool := cfg.Tools[0].Subtools[0]
+		if !reflect.DeepEqual(subtool.Args, yamlConfig.Tools[0].Subtools[0].Args) {
+			t.Errorf("Expected args %v in %s, got %v", yamlConfig.Tools[0].Subtools[0].Args, name, subtool.Args)
+		}
+		if subtool.DangerLevel != "high" {
+			t.Errorf("Expected danger level high in %s, got %s", name, subtool.DangerLevel)
+		}
+	}
+
+	// The extension can be overridden
+	cfg, err := LoadConfigFormat(filepath.Join(tempDir, "config.conf"), FormatTOML)
+	if err != nil {
+		t.Fatalf("LoadConfigFormat failed: %v", err)
+	}
+	if len(cfg.Tools) != 1 || cfg.Tools[0].Name != "echo" {
+		t.Errorf("Expected tool echo, got %v", cfg.Tools)
+	}
+
+	if _, err := LoadConfigFormat(filepath.Join(tempDir, "config.conf"), "xml"); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+func TestLoadConfigJSONPositions(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.json": `{
+  "version": 2,
+  "tools": [
+    {"name": "echo", "comand": ["echo"]}
+  ]
+}
+`,
+		"broken.json": `{
+  "tools": [
+    {"name": "echo",}
+  ]
+}
+`,
+	})
+
+	// Validation errors point into the JSON file
+	_, err := LoadConfig(filepath.Join(tempDir, "config.json"))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if errs[0].Pos.Line != 4 || errs[0].Pos.Column != 22 {
+		t.Errorf("Expected error at 4:22, got %s", errs[0].Pos)
+	}
+
+	// Syntax errors report the line
+	_, err = LoadConfig(filepath.Join(tempDir, "broken.json"))
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if errs[0].Pos.Line != 3 {
+		t.Errorf("Expected syntax error on line 3, got %s", errs[0].Pos)
+	}
+}
+
+func TestLoadConfigConfDirFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `tools:
+  - name: a
+    command: [a]
+`,
+		".operations/conf.d/b.json": `{"tools": [{"name": "b", "command": ["b"]}]}`,
+		".operations/conf.d/c.toml": `[[tools]]
+name = "c"
+command = ["c"]
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	var names []string
+	for _, tool := range cfg.Tools {
+		names = append(names, tool.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected tools [a b c], got %v", names)
+	}
+}
+
+func TestLoadConfigHomeFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	writeFiles(t, tempDir, map[string]string{
+		".operations/config.toml": `[[tools]]
+name = "home"
+command = ["home"]
+`,
+	})
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Tools) != 1 || cfg.Tools[0].Name != "home" {
+		t.Errorf("Expected the tool from ~/.operations/config.toml, got %v", cfg.Tools)
+	}
+
+	// YAML takes precedence over the other formats
+	writeFiles(t, tempDir, map[string]string{
+		".operations/config.yaml": `tools:
+  - name: yaml
+    command: [yaml]
+`,
+	})
+	cfg, err = LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Tools) != 1 || cfg.Tools[0].Name != "yaml" {
+		t.Errorf("Expected the tool from ~/.operations/config.yaml, got %v", cfg.Tools)
+	}
+}
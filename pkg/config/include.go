@@ -0,0 +1,335 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfDirName is the name of the directory in ~/.operations whose *.yaml,
+// *.json and *.toml files are merged into the configuration
+const ConfDirName = "conf.d"
+
+// ConfDir returns ~/.operations/conf.d, which is merged into every
+// configuration wherever the main config file is
+func ConfDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".operations", ConfDirName), nil
+}
+
+// loader loads config files and merges the files they include. Each file is
+// loaded at most once, which also stops include cycles.
+type loader struct {
+	loaded map[string]bool
+	files  []string
+	format Format
+
+	// definitions collects the definitions of every file, so that a file can
+	// reference those of another. defined maps "kind/name" to the file
+	// defining it.
+	definitions *yaml.Node
+	defined     map[string]string
+	errs        ValidationErrors
+}
+
+// configFile is a parsed config file and the files merged into it, in order
+type configFile struct {
+	path     string
+	root     *yaml.Node
+	warnings []Issue
+	children []*configFile
+}
+
+// newLoader creates a new loader. format applies to the main config file;
+// other files are read according to their extension.
+func newLoader(format Format) *loader {
+	return &loader{
+		loaded:      make(map[string]bool),
+		format:      format,
+		definitions: &yaml.Node{Kind: yaml.MappingNode},
+		defined:     make(map[string]string),
+	}
+}
+
+// parse reads a config file and the files it includes and collects their
+// definitions. Include patterns are globs relative to the directory of the
+// including file.
+func (l *loader) parse(path string) (*configFile, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		l.loaded[abs] = true
+	}
+
+	format := FormatFromPath(path)
+	if len(l.files) == 0 && l.format != "" {
+		format = l.format
+	}
+
+	root, warnings, err := parseConfigFile(path, format)
+	if err != nil {
+		return nil, err
+	}
+	l.files = append(l.files, path)
+	file := &configFile{path: path, root: root, warnings: warnings}
+	if len(root.Content) == 0 {
+		return file, nil
+	}
+	doc := root.Content[0]
+	l.addDefinitions(doc, path)
+
+	var includes []string
+	if node := mappingValue(doc, "include"); node != nil {
+		if err := node.Decode(&includes); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", decodeErrors(path, err))
+		}
+	}
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %s in %s: %w", pattern, path, err)
+		}
+		if len(matches) == 0 && !hasGlobMeta(pattern) {
+			return nil, fmt.Errorf("included file %s in %s not found", pattern, path)
+		}
+
+		children, err := l.parseFiles(matches)
+		if err != nil {
+			return nil, err
+		}
+		file.children = append(file.children, children...)
+	}
+
+	return file, nil
+}
+
+// parseConfDir parses every *.yaml, *.json and *.toml file in dir. A missing
+// directory is not an error.
+func (l *loader) parseConfDir(dir string) ([]*configFile, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, nil
+	}
+
+	var matches []string
+	for _, pattern := range []string{"*.yaml", "*.json", "*.toml"} {
+		files, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, files...)
+	}
+
+	return l.parseFiles(matches)
+}
+
+// parseFiles parses the given files in sorted order, skipping those already
+// loaded
+func (l *loader) parseFiles(files []string) ([]*configFile, error) {
+	sort.Strings(files)
+	var parsed []*configFile
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil && l.loaded[abs] {
+			continue
+		}
+
+		part, err := l.parse(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, part)
+	}
+	return parsed, nil
+}
+
+// addDefinitions adds the definitions of a file to those shared by all files.
+// A definition with the same kind and name in two files is an error.
+func (l *loader) addDefinitions(doc *yaml.Node, path string) {
+	definitions := mappingValue(doc, "definitions")
+	if definitions == nil || definitions.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(definitions.Content); i += 2 {
+		kindKey, kind := definitions.Content[i], definitions.Content[i+1]
+		if kind.Kind != yaml.MappingNode {
+			continue
+		}
+		shared := mappingValue(l.definitions, kindKey.Value)
+		if shared == nil {
+			shared = &yaml.Node{Kind: yaml.MappingNode}
+			setMappingValue(l.definitions, kindKey, shared)
+		}
+		for j := 0; j+1 < len(kind.Content); j += 2 {
+			name := kind.Content[j]
+			key := kindKey.Value + "/" + name.Value
+			if owner, exists := l.defined[key]; exists {
+				pos := Position{File: path, Line: name.Line, Column: name.Column}
+				l.errs = append(l.errs, newIssue(pos, "definition %s is already defined in %s", key, owner))
+				continue
+			}
+			l.defined[key] = path
+			setMappingValue(shared, name, kind.Content[j+1])
+		}
+	}
+}
+
+// build decodes a parsed file and merges the files it includes into it
+func (l *loader) build(file *configFile) (*Config, error) {
+	shared := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "definitions"}, l.definitions,
+	}}
+	config, err := decodeConfigFile(file.path, file.root, file.warnings, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range file.children {
+		part, err := l.build(child)
+		if err != nil {
+			return nil, err
+		}
+		if errs := config.merge(part, child.path); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+	return config, nil
+}
+
+// merge merges another config into c using the following rules:
+//   - tools are appended; a tool with the same name as an existing one is an
+//     error unless it is marked override, in which case it replaces it
+//   - actions are appended; two actions for the same danger level are an error
+//   - ssh and fanout may be defined by only one file
+//   - a target or profile may be defined by only one file
+//   - definitions are combined; each name is defined by only one file,
+//     which the loader checks before references are resolved
+//   - template_env entries are combined
+func (c *Config) merge(other *Config, file string) ValidationErrors {
+	var errs ValidationErrors
+
+	for _, action := range other.Actions {
+		if i := c.findAction(action.DangerLevel); i >= 0 {
+			errs = append(errs, newIssue(action.Pos, "duplicate action for danger level %s (first defined at %s)",
+				action.DangerLevel, c.Actions[i].Pos))
+			continue
+		}
+		c.Actions = append(c.Actions, action)
+	}
+
+	for _, tool := range other.Tools {
+		i := c.findTool(tool.Name)
+		switch {
+		case i < 0:
+			c.Tools = append(c.Tools, tool)
+		case tool.Override:
+			c.Tools[i] = tool
+		default:
+			errs = append(errs, newIssue(tool.Pos, "duplicate tool %s (first defined at %s); set override: true to replace it",
+				tool.Name, c.Tools[i].Pos))
+		}
+	}
+
+	if other.SSH != nil {
+		if c.SSH != nil {
+			errs = append(errs, newIssue(Position{File: file}, "ssh is already defined by another config file"))
+		} else {
+			c.SSH = other.SSH
+		}
+	}
+
+	if other.FanOut != nil {
+		if c.FanOut != nil {
+			errs = append(errs, newIssue(Position{File: file}, "fanout is already defined by another config file"))
+		} else {
+			c.FanOut = other.FanOut
+		}
+	}
+
+	for name, target := range other.Targets {
+		if _, exists := c.Targets[name]; exists {
+			errs = append(errs, newIssue(target.Pos, "target %s is already defined by another config file", name))
+			continue
+		}
+		if c.Targets == nil {
+			c.Targets = make(map[string]Target)
+		}
+		c.Targets[name] = target
+	}
+
+	for name, profile := range other.Profiles {
+		if _, exists := c.Profiles[name]; exists {
+			errs = append(errs, newIssue(Position{File: file}, "profile %s is already defined by another config file", name))
+			continue
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]Profile)
+		}
+		c.Profiles[name] = profile
+	}
+
+	if other.Definitions != nil {
+		if c.Definitions == nil {
+			c.Definitions = &Definitions{}
+		}
+		c.Definitions.merge(other.Definitions)
+	}
+
+	for _, name := range other.TemplateEnv {
+		if !containsString(c.TemplateEnv, name) {
+			c.TemplateEnv = append(c.TemplateEnv, name)
+		}
+	}
+
+	c.warnings = append(c.warnings, other.warnings...)
+
+	return errs
+}
+
+// findAction returns the index of the action for a danger level, or -1
+func (c *Config) findAction(dangerLevel string) int {
+	for i := range c.Actions {
+		if c.Actions[i].DangerLevel == dangerLevel {
+			return i
+		}
+	}
+	return -1
+}
+
+// findTool returns the index of the tool with the given name, or -1
+func (c *Config) findTool(name string) int {
+	for i := range c.Tools {
+		if c.Tools[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasGlobMeta reports whether a path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	for _, c := range path {
+		switch c {
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
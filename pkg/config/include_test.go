@@ -0,0 +1,182 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes test config files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test config file: %v", err)
+		}
+	}
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `
+include:
+  - teams/*.yaml
+actions:
+  - danger_level: high
+    type: confirm
+tools:
+  - name: echo
+    command: [echo]
+`,
+		"teams/a.yaml": `
+tools:
+  - name: kubectl
+    command: [kubectl]
+`,
+		"teams/b.yaml": `
+include:
+  - ../config.yaml
+tools:
+  - name: echo
+    override: true
+    command: [echo, "-n"]
+ssh:
+  host: example.com
+`,
+		".operations/conf.d/extra.yaml": `
+actions:
+  - danger_level: low
+    type: force
+tools:
+  - name: systemctl
+    command: [systemctl]
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	names := make([]string, 0, len(cfg.Tools))
+	for _, tool := range cfg.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "echo,kubectl,systemctl" {
+		t.Errorf("Expected tools echo,kubectl,systemctl, got %v", names)
+	}
+
+	// The overriding tool replaces the original and records its own file
+	if len(cfg.Tools[0].Command) != 2 {
+		t.Errorf("Expected overridden echo command, got %v", cfg.Tools[0].Command)
+	}
+	if cfg.Tools[0].Pos.File != filepath.Join(tempDir, "teams/b.yaml") {
+		t.Errorf("Expected echo to come from teams/b.yaml, got %s", cfg.Tools[0].Pos.File)
+	}
+	if cfg.Tools[2].Pos.File != filepath.Join(tempDir, ".operations/conf.d/extra.yaml") {
+		t.Errorf("Expected systemctl to come from ~/.operations/conf.d/extra.yaml, got %s", cfg.Tools[2].Pos.File)
+	}
+
+	if len(cfg.Actions) != 2 {
+		t.Errorf("Expected 2 actions, got %d", len(cfg.Actions))
+	}
+	if cfg.SSH == nil || cfg.SSH.Host != "example.com" {
+		t.Errorf("Expected ssh from included file, got %v", cfg.SSH)
+	}
+}
+
+func TestLoadConfigConfDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := t.TempDir()
+	writeFiles(t, home, map[string]string{
+		".operations/conf.d/team.yaml": `
+tools:
+  - name: team
+    command: [team]
+`,
+	})
+	writeFiles(t, project, map[string]string{
+		"config.yaml": `
+tools:
+  - name: project
+    command: [project]
+`,
+		"conf.d/stray.yaml": `
+tools:
+  - name: stray
+    command: [stray]
+`,
+	})
+
+	// ~/.operations/conf.d is merged into a config file anywhere, and a
+	// conf.d directory next to it is not
+	cfg, err := LoadConfig(filepath.Join(project, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	var names []string
+	for _, tool := range cfg.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "project,team" {
+		t.Errorf("Expected tools project,team, got %v", names)
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name: "duplicate tool",
+			files: map[string]string{
+				"config.yaml": "include: [other.yaml]\ntools:\n  - name: echo\n    command: [echo]\n",
+				"other.yaml":  "tools:\n  - name: echo\n    command: [echo]\n",
+			},
+			expected: "duplicate tool echo",
+		},
+		{
+			name: "duplicate action",
+			files: map[string]string{
+				"config.yaml": "include: [other.yaml]\nactions:\n  - danger_level: high\n    type: confirm\n",
+				"other.yaml":  "actions:\n  - danger_level: high\n    type: force\n",
+			},
+			expected: "duplicate action for danger level high",
+		},
+		{
+			name: "duplicate ssh",
+			files: map[string]string{
+				"config.yaml": "include: [other.yaml]\nssh:\n  host: a\n",
+				"other.yaml":  "ssh:\n  host: b\n",
+			},
+			expected: "ssh is already defined",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"config.yaml": "include: [missing.yaml]\n",
+			},
+			expected: "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		tempDir := t.TempDir()
+		writeFiles(t, tempDir, tt.files)
+
+		_, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the configuration format read by this
+// release. Files without a version are version 1.
+const CurrentVersion = 2
+
+// migration upgrades a configuration document from one version to the next
+type migration struct {
+	from  int
+	apply func(doc *yaml.Node) []string
+}
+
+// migrations lists the upgrade steps in order
+var migrations = []migration{
+	{from: 1, apply: migrateV1},
+}
+
+// fileVersion returns the version declared in a document
+func fileVersion(doc *yaml.Node) (int, error) {
+	if doc.Kind != yaml.MappingNode {
+		return 1, nil
+	}
+	node := mappingValue(doc, "version")
+	if node == nil {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("line %d: invalid version: %s", node.Line, node.Value)
+	}
+	return version, nil
+}
+
+// checkVersion checks the version of a document and the keys it uses. Files
+// in the current version are decoded strictly, so unknown keys are errors.
+// Older files keep the lenient behaviour, where unknown keys are ignored and
+// only reported as warnings.
+func checkVersion(doc *yaml.Node, file string) ([]Issue, error) {
+	version, err := fileVersion(doc)
+	if err != nil {
+		return nil, decodeErrors(file, err)
+	}
+	if version > CurrentVersion {
+		return nil, ValidationErrors{newIssue(Position{File: file}, "unsupported config version %d (this release supports up to %d)", version, CurrentVersion)}
+	}
+
+	var issues []Issue
+	for _, key := range unknownKeys(doc, reflect.TypeOf(Config{})) {
+		issue := newIssue(Position{File: file, Line: key.node().Line, Column: key.node().Column},
+			"unknown key %s in %s", key.node().Value, key.context)
+		if version < CurrentVersion {
+			issue.Warning = true
+			issue.Message += fmt.Sprintf(" (ignored in version %d files; run `operations config migrate`)", version)
+		}
+		issues = append(issues, issue)
+	}
+
+	if version == CurrentVersion {
+		var errs ValidationErrors
+		for _, issue := range issues {
+			errs = append(errs, issue)
+		}
+		if len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	return issues, nil
+}
+
+// unknownKey is a key in a mapping node that no config field uses
+type unknownKey struct {
+	mapping *yaml.Node
+	index   int
+	context string
+}
+
+// node returns the key node
+func (k unknownKey) node() *yaml.Node {
+	return k.mapping.Content[k.index]
+}
+
+// unknownKeys walks a document along a Go type and returns the keys that do
+// not correspond to any field. $ref keys are always allowed.
+func unknownKeys(node *yaml.Node, t reflect.Type) []unknownKey {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var keys []unknownKey
+	switch {
+	case t == reflect.TypeOf(Args{}):
+		// Args elements are strings or arg groups
+		for _, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				keys = append(keys, unknownKeys(item, reflect.TypeOf(ArgGroup{}))...)
+			}
+		}
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		for _, field := range schemaFields(t) {
+			fields[yamlName(field)] = field.Type
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if key == RefKey {
+				continue
+			}
+			fieldType, ok := fields[key]
+			if !ok {
+				keys = append(keys, unknownKey{mapping: node, index: i, context: strings.ToLower(t.Name())})
+				continue
+			}
+			keys = append(keys, unknownKeys(node.Content[i+1], fieldType)...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			keys = append(keys, unknownKeys(node.Content[i], t.Elem())...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			keys = append(keys, unknownKeys(item, t.Elem())...)
+		}
+	}
+	return keys
+}
+
+// Migrate upgrades a configuration file to the current version. Comments
+// and formatting are kept as far as the YAML encoder allows. It returns the
+// new content and a description of each change; a file that is already
+// current is returned unchanged with no changes.
+func Migrate(data []byte) ([]byte, []string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config file must be a mapping")
+	}
+	doc := root.Content[0]
+
+	version, err := fileVersion(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("unsupported config version %d (this release supports up to %d)", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, nil, nil
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		changes = append(changes, m.apply(doc)...)
+		setVersion(doc, m.from+1)
+		changes = append(changes, fmt.Sprintf("set version to %d", m.from+1))
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), changes, nil
+}
+
+// migrateV1 upgrades a version 1 file. Version 2 decodes strictly, so keys
+// that version 1 silently ignored are turned into comments.
+func migrateV1(doc *yaml.Node) []string {
+	var changes []string
+
+	keys := unknownKeys(doc, reflect.TypeOf(Config{}))
+	// Remove from the end so indices of earlier keys stay valid
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		keyNode := key.node()
+		changes = append(changes, fmt.Sprintf("line %d: commented out unknown key %s in %s", keyNode.Line, keyNode.Value, key.context))
+		commentOut(key.mapping, key.index)
+	}
+
+	// Report changes in file order
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	return changes
+}
+
+// commentOut removes a key and its value from a mapping and keeps them as a
+// comment next to the remaining keys
+func commentOut(mapping *yaml.Node, index int) {
+	pair := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{mapping.Content[index], mapping.Content[index+1]}}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(pair); err != nil {
+		return
+	}
+	out := buf.Bytes()
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "# " + line
+	}
+	comment := strings.Join(lines, "\n")
+
+	mapping.Content = append(mapping.Content[:index], mapping.Content[index+2:]...)
+	switch {
+	case index < len(mapping.Content):
+		next := mapping.Content[index]
+		next.HeadComment = joinComments(comment, next.HeadComment)
+	case index > 0:
+		prev := mapping.Content[index-2]
+		prev.FootComment = joinComments(prev.FootComment, comment)
+	default:
+		mapping.FootComment = joinComments(mapping.FootComment, comment)
+	}
+}
+
+// joinComments joins non-empty comments with a newline
+func joinComments(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "\n" + b
+}
+
+// setVersion sets the version key of a document, adding it as the first key
+// when missing
+func setVersion(doc *yaml.Node, version int) {
+	value := strconv.Itoa(version)
+	if node := mappingValue(doc, "version"); node != nil {
+		node.Value = value
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	doc.Content = append([]*yaml.Node{key, val}, doc.Content...)
+
+	// Keep a comment at the top of the file above the version key
+	if len(doc.Content) > 2 && doc.Content[2].HeadComment != "" {
+		key.HeadComment = doc.Content[2].HeadComment
+		doc.Content[2].HeadComment = ""
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"current.yaml": `version: 2
+definitions:
+  params:
+    ns:
+      type: string
+tools:
+  - name: kubectl
+    command: [kubectl]
+    descripton: typo
+    params:
+      namespace:
+        $ref: "#/definitions/params/ns"
+        requird: true
+`,
+		"legacy.yaml": `tools:
+  - name: kubectl
+    command: [kubectl]
+    descripton: typo
+`,
+		"future.yaml": `version: 3
+tools: []
+`,
+	})
+
+	// Unknown keys are errors in files of the current version
+	_, err := LoadConfig(filepath.Join(tempDir, "current.yaml"))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Pos.Line != 9 || !strings.Contains(errs[0].Message, "unknown key descripton in tool") {
+		t.Errorf("Unexpected first error: %v", errs[0])
+	}
+	if errs[1].Pos.Line != 13 || !strings.Contains(errs[1].Message, "unknown key requird in parameter") {
+		t.Errorf("Unexpected second error: %v", errs[1])
+	}
+
+	// Older files are decoded leniently and report unknown keys as warnings
+	cfg, err := LoadConfig(filepath.Join(tempDir, "legacy.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+	issues := cfg.Lint()
+	if len(issues) != 1 || !issues[0].Warning || !strings.Contains(issues[0].Message, "config migrate") {
+		t.Errorf("Expected one migrate warning, got %v", issues)
+	}
+
+	// Newer versions are rejected
+	_, err = LoadConfig(filepath.Join(tempDir, "future.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported config version 3") {
+		t.Errorf("Expected unsupported version error, got %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	input := `# Operations config
+tools:
+  - name: kubectl # the kubectl tool
+    command: [kubectl]
+    descripton: typo
+    params:
+      ns:
+        type: string
+        requird: true
+`
+	expected := `# Operations config
+version: 2
+tools:
+  - name: kubectl # the kubectl tool
+    command: [kubectl]
+    # descripton: typo
+    params:
+      ns:
+        type: string
+        # requird: true
+`
+
+	out, changes, err := Migrate([]byte(input))
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Unexpected migrated config:\n%s", out)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %v", changes)
+	}
+	if changes[0] != "line 5: commented out unknown key descripton in tool" {
+		t.Errorf("Unexpected first change: %s", changes[0])
+	}
+	if changes[2] != "set version to 2" {
+		t.Errorf("Unexpected last change: %s", changes[2])
+	}
+
+	// The migrated file is read strictly without errors
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{"config.yaml": string(out)})
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Expected version %d, got %d", CurrentVersion, cfg.Version)
+	}
+
+	// Current files are left unchanged
+	again, changes, err := Migrate(out)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(changes) != 0 || string(again) != string(out) {
+		t.Errorf("Expected no changes for a current file, got %v", changes)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProfileEnv is the environment variable that selects a profile when the
+// --profile flag is not given
+const ProfileEnv = "OPERATIONS_PROFILE"
+
+// NoDangerLevel is the escalate key for subtools without a danger level
+const NoDangerLevel = "none"
+
+// Profile overrides parts of the configuration for one environment
+type Profile struct {
+	// SSH replaces the ssh settings
+	SSH *SSHConfig `yaml:"ssh,omitempty"`
+	// Defaults sets the default value of every parameter with the given name
+	Defaults map[string]string `yaml:"defaults,omitempty"`
+	// Actions replace the actions for the same danger level, or are added
+	Actions []Action `yaml:"actions,omitempty"`
+	// Escalate maps a danger level to the level used in this profile,
+	// e.g. {low: high} makes every low subtool high. The key "none" matches
+	// subtools without a danger level.
+	Escalate map[string]string `yaml:"escalate,omitempty"`
+	// DangerLevels sets the danger level of subtools by tool path. Keys may
+	// be glob patterns such as "kubectl_delete_*".
+	DangerLevels map[string]string `yaml:"danger_levels,omitempty"`
+}
+
+// ApplyProfile applies the named profile to the configuration. An empty
+// name leaves the configuration unchanged.
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	// Check patterns before changing anything
+	for pattern := range profile.DangerLevels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid danger_levels pattern %s in profile %s: %w", pattern, name, err)
+		}
+	}
+
+	if profile.SSH != nil {
+		c.SSH = profile.SSH
+	}
+
+	for _, action := range profile.Actions {
+		if i := c.findAction(action.DangerLevel); i >= 0 {
+			c.Actions[i] = action
+		} else {
+			c.Actions = append(c.Actions, action)
+		}
+	}
+
+	for i := range c.Tools {
+		applyDefaults(c.Tools[i].Params, profile.Defaults)
+		applySubtoolProfile(c.Tools[i].Subtools, c.Tools[i].Name, profile)
+	}
+
+	return nil
+}
+
+// validateProfile checks that every danger level a profile sets has an
+// action, either in the configuration or in the profile itself. Without one
+// a subtool would run without confirmation.
+func (c *Config) validateProfile(name string) []Issue {
+	profile := c.Profiles[name]
+	pos := Position{File: c.mainFile()}
+
+	hasAction := func(level string) bool {
+		if level == "" || c.findAction(level) >= 0 {
+			return true
+		}
+		for _, action := range profile.Actions {
+			if action.DangerLevel == level {
+				return true
+			}
+		}
+		return false
+	}
+
+	var issues []Issue
+	for _, from := range sortedKeys(profile.Escalate) {
+		if level := profile.Escalate[from]; !hasAction(level) {
+			issues = append(issues, newIssue(pos, "profile %s escalates %s to danger level %s, which has no action", name, from, level))
+		}
+	}
+	for _, pattern := range sortedKeys(profile.DangerLevels) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			issues = append(issues, newIssue(pos, "invalid danger_levels pattern %s in profile %s: %v", pattern, name, err))
+		}
+		if level := profile.DangerLevels[pattern]; !hasAction(level) {
+			issues = append(issues, newIssue(pos, "profile %s sets danger level %s for %s, which has no action", name, level, pattern))
+		}
+	}
+	return issues
+}
+
+// applySubtoolProfile applies parameter defaults and danger levels to
+// subtools recursively
+func applySubtoolProfile(subtools []Subtool, parentPath string, profile Profile) {
+	for i := range subtools {
+		subtool := &subtools[i]
+		toolPath := parentPath + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+
+		applyDefaults(subtool.Params, profile.Defaults)
+
+		current := subtool.DangerLevel
+		if current == "" {
+			current = NoDangerLevel
+		}
+		if level, ok := profile.Escalate[current]; ok {
+			subtool.DangerLevel = level
+		}
+		if level, ok := matchDangerLevel(profile.DangerLevels, toolPath); ok {
+			subtool.DangerLevel = level
+		}
+
+		applySubtoolProfile(subtool.Subtools, toolPath, profile)
+	}
+}
+
+// applyDefaults sets parameter defaults by name
+func applyDefaults(params Parameters, defaults map[string]string) {
+	for name, value := range defaults {
+		if param, ok := params[name]; ok {
+			param.Default = value
+			params[name] = param
+		}
+	}
+}
+
+// matchDangerLevel returns the danger level for a tool path. An exact key
+// takes precedence over patterns; among patterns the longest one wins.
+func matchDangerLevel(levels map[string]string, toolPath string) (string, bool) {
+	if level, ok := levels[toolPath]; ok {
+		return level, true
+	}
+
+	patterns := make([]string, 0, len(levels))
+	for pattern := range levels {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, toolPath); matched {
+			return levels[pattern], true
+		}
+	}
+	return "", false
+}
+
+// sortedProfileNames returns profile names in a stable order
+func sortedProfileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys returns the keys of a map in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `
+actions:
+  - danger_level: high
+    type: force
+ssh:
+  host: dev.example.com
+profiles:
+  prod:
+    ssh:
+      host: prod.example.com
+    defaults:
+      namespace: production
+    actions:
+      - danger_level: high
+        type: confirm
+    escalate:
+      none: low
+      low: high
+      medium: high
+    danger_levels:
+      kubectl_get_*: medium
+      kubectl_get_secret: high
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+        default: default
+    subtools:
+      - name: get pod
+        args: ["get", "pod", "-n", "{{.namespace}}"]
+      - name: get secret
+        args: ["get", "secret", "-n", "{{.namespace}}"]
+      - name: delete pod
+        danger_level: low
+        args: ["delete", "pod", "-n", "{{.namespace}}"]
+      - name: logs
+        args: ["logs", "-n", "{{.namespace}}"]
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// No profile leaves the configuration unchanged
+	if err := cfg.ApplyProfile(""); err != nil {
+		t.Fatalf("ApplyProfile failed: %v", err)
+	}
+	if cfg.SSH.Host != "dev.example.com" {
+		t.Errorf("Expected ssh host dev.example.com, got %s", cfg.SSH.Host)
+	}
+
+	if err := cfg.ApplyProfile("prod"); err != nil {
+		t.Fatalf("ApplyProfile failed: %v", err)
+	}
+
+	if cfg.SSH.Host != "prod.example.com" {
+		t.Errorf("Expected ssh host prod.example.com, got %s", cfg.SSH.Host)
+	}
+	if len(cfg.Actions) != 1 || cfg.Actions[0].Type != "confirm" {
+		t.Errorf("Expected high action to be replaced, got %v", cfg.Actions)
+	}
+	if cfg.Tools[0].Params["namespace"].Default != "production" {
+		t.Errorf("Expected namespace default 'production', got '%s'", cfg.Tools[0].Params["namespace"].Default)
+	}
+
+	expected := map[string]string{
+		"get pod":    "medium",
+		"get secret": "high",
+		"delete pod": "high",
+		"logs":       "low",
+	}
+	for _, subtool := range cfg.Tools[0].Subtools {
+		if subtool.DangerLevel != expected[subtool.Name] {
+			t.Errorf("Expected %s danger level '%s', got '%s'", subtool.Name, expected[subtool.Name], subtool.DangerLevel)
+		}
+	}
+
+	if err := cfg.ApplyProfile("missing"); err == nil {
+		t.Errorf("ApplyProfile should fail for unknown profile")
+	}
+}
+
+func TestValidateProfileDangerLevels(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `
+actions:
+  - danger_level: low
+    type: confirm
+profiles:
+  prod:
+    actions:
+      - danger_level: medium
+        type: confirm
+    escalate:
+      none: low
+      low: medium
+      medium: high
+    danger_levels:
+      kubectl_delete_*: critical
+      kubectl_get_*: medium
+      "[": low
+tools:
+  - name: kubectl
+    command: [kubectl]
+    subtools:
+      - name: get pod
+        args: ["get", "pod"]
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error for danger levels without actions")
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+
+	expected := []string{
+		"profile prod escalates medium to danger level high, which has no action",
+		"invalid danger_levels pattern [ in profile prod: syntax error in pattern",
+		"profile prod sets danger level critical for kubectl_delete_*, which has no action",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, message := range expected {
+		if errs[i].Message != message {
+			t.Errorf("Expected error %q, got %q", message, errs[i].Message)
+		}
+	}
+}
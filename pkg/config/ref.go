@@ -0,0 +1,188 @@
+package config
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RefKey is the key of a mapping that references another part of the file
+const RefKey = "$ref"
+
+// Definitions holds reusable fragments that can be referenced with $ref,
+// e.g. {$ref: "#/definitions/params/pod"}
+type Definitions struct {
+	Params      Parameters              `yaml:"params,omitempty"`
+	Validations map[string][]Validation `yaml:"validations,omitempty"`
+	Subtools    map[string]Subtool      `yaml:"subtools,omitempty"`
+}
+
+// merge adds the definitions of other that d does not have
+func (d *Definitions) merge(other *Definitions) {
+	for name, param := range other.Params {
+		if _, exists := d.Params[name]; !exists {
+			if d.Params == nil {
+				d.Params = make(Parameters)
+			}
+			d.Params[name] = param
+		}
+	}
+	for name, validations := range other.Validations {
+		if _, exists := d.Validations[name]; !exists {
+			if d.Validations == nil {
+				d.Validations = make(map[string][]Validation)
+			}
+			d.Validations[name] = validations
+		}
+	}
+	for name, subtool := range other.Subtools {
+		if _, exists := d.Subtools[name]; !exists {
+			if d.Subtools == nil {
+				d.Subtools = make(map[string]Subtool)
+			}
+			d.Subtools[name] = subtool
+		}
+	}
+}
+
+// refResolver replaces $ref mappings in a YAML document with copies of the
+// nodes they reference
+type refResolver struct {
+	file   string
+	doc    *yaml.Node
+	shared *yaml.Node
+	stack  []string
+	errs   ValidationErrors
+}
+
+// resolveRefs resolves every $ref in a YAML document. A reference is a JSON
+// pointer into the same file, such as "#/definitions/params/pod", or into
+// shared, which holds the definitions of every loaded file. Other keys next
+// to $ref override the keys of the referenced mapping.
+func resolveRefs(root *yaml.Node, file string, shared *yaml.Node) error {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+
+	r := &refResolver{file: file, doc: root.Content[0], shared: shared}
+	r.resolve(root.Content[0])
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	return nil
+}
+
+// resolve resolves references in a node and its children in place
+func (r *refResolver) resolve(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if refNode := mappingValue(node, RefKey); refNode != nil {
+			r.replace(node, refNode)
+			return
+		}
+		for _, child := range node.Content {
+			r.resolve(child)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			r.resolve(child)
+		}
+	}
+}
+
+// replace replaces a $ref mapping with the resolved target
+func (r *refResolver) replace(node *yaml.Node, refNode *yaml.Node) {
+	ref := refNode.Value
+	pos := Position{File: r.file, Line: refNode.Line, Column: refNode.Column}
+
+	for i, seen := range r.stack {
+		if seen == ref {
+			cycle := append(append([]string{}, r.stack[i:]...), ref)
+			r.errs = append(r.errs, newIssue(pos, "reference cycle: %s", strings.Join(cycle, " -> ")))
+			return
+		}
+	}
+
+	target := lookup(r.doc, ref)
+	if target == nil && r.shared != nil {
+		target = lookup(r.shared, ref)
+	}
+	if target == nil {
+		r.errs = append(r.errs, newIssue(pos, "dangling reference: %s", ref))
+		return
+	}
+
+	// Resolve references inside the copied target
+	resolved := copyNode(target)
+	r.stack = append(r.stack, ref)
+	r.resolve(resolved)
+	r.stack = r.stack[:len(r.stack)-1]
+
+	// Keys next to $ref override the referenced mapping
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == RefKey {
+			continue
+		}
+		if resolved.Kind != yaml.MappingNode {
+			r.errs = append(r.errs, newIssue(pos, "reference %s is not a mapping and cannot have other keys", ref))
+			return
+		}
+		r.resolve(value)
+		setMappingValue(resolved, key, value)
+	}
+
+	*node = *resolved
+}
+
+// lookup finds the node in doc a JSON pointer such as
+// "#/definitions/params/pod" points to, or nil
+func lookup(doc *yaml.Node, ref string) *yaml.Node {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+
+	node := doc
+	for _, segment := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		node = mappingValue(node, segment)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets or replaces the value for key in a mapping node
+func setMappingValue(mapping *yaml.Node, key *yaml.Node, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key.Value {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+// copyNode returns a deep copy of a YAML node
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// refExample uses references for parameters, validations and subtools
+const refExample = `
+definitions:
+  params:
+    pod:
+      description: The pod
+      type: string
+      required: true
+  validations:
+    protected:
+      - danger_level: high
+        exclude: [kube-system]
+  subtools:
+    logs:
+      name: logs
+      params:
+        pod: {$ref: "#/definitions/params/pod"}
+      args: ["logs", "{{.pod}}"]
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+        validate: {$ref: "#/definitions/validations/protected"}
+    subtools:
+      - name: delete pod
+        params:
+          pod:
+            $ref: "#/definitions/params/pod"
+            description: The pod to delete
+        args: ["delete", "pod", "{{.pod}}", "-n", "{{.namespace}}"]
+      - $ref: "#/definitions/subtools/logs"
+`
+
+func TestLoadConfigRefs(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": refExample,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tool := cfg.Tools[0]
+	if len(tool.Params["namespace"].Validate) != 1 || tool.Params["namespace"].Validate[0].Exclude[0] != "kube-system" {
+		t.Errorf("Expected validation from definitions, got %v", tool.Params["namespace"].Validate)
+	}
+
+	// Keys next to $ref override the referenced definition
+	pod := tool.Subtools[0].Params["pod"]
+	if pod.Description != "The pod to delete" || pod.Type != "string" || !pod.Required {
+		t.Errorf("Expected overridden pod parameter, got %+v", pod)
+	}
+
+	// Subtool fragments are resolved, including nested references
+	if len(tool.Subtools) != 2 || tool.Subtools[1].Name != "logs" {
+		t.Fatalf("Expected logs subtool from definitions, got %v", tool.Subtools)
+	}
+	if tool.Subtools[1].Params["pod"].Description != "The pod" {
+		t.Errorf("Expected nested reference to be resolved, got %+v", tool.Subtools[1].Params["pod"])
+	}
+
+	if cfg.Definitions == nil || len(cfg.Definitions.Params) != 1 {
+		t.Errorf("Expected definitions to be decoded, got %v", cfg.Definitions)
+	}
+}
+
+func TestLoadConfigRefErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "dangling reference",
+			content: `
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      pod: {$ref: "#/definitions/params/missing"}
+`,
+			expected: "6:19: dangling reference: #/definitions/params/missing",
+		},
+		{
+			name: "reference cycle",
+			content: `
+definitions:
+  subtools:
+    a:
+      name: a
+      subtools:
+        - $ref: "#/definitions/subtools/b"
+    b:
+      name: b
+      subtools:
+        - $ref: "#/definitions/subtools/a"
+tools:
+  - name: kubectl
+    command: [kubectl]
+    subtools:
+      - $ref: "#/definitions/subtools/a"
+`,
+			expected: "reference cycle: #/definitions/subtools/a -> #/definitions/subtools/b -> #/definitions/subtools/a",
+		},
+		{
+			name: "override on non-mapping",
+			content: `
+definitions:
+  validations:
+    protected:
+      - danger_level: high
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+        validate:
+          $ref: "#/definitions/validations/protected"
+          extra: true
+`,
+			expected: "is not a mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		tempDir := t.TempDir()
+		writeFiles(t, tempDir, map[string]string{"config.yaml": tt.content})
+
+		_, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestLoadConfigRefsAcrossFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `
+include: [extra.yaml]
+definitions:
+  params:
+    pod:
+      description: The pod
+      type: string
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+        validate: {$ref: "#/definitions/validations/protected"}
+`,
+		"extra.yaml": `
+definitions:
+  validations:
+    protected:
+      - danger_level: high
+        exclude: [kube-system]
+tools:
+  - name: logs
+    command: [kubectl, logs]
+    params:
+      pod: {$ref: "#/definitions/params/pod"}
+`,
+		".operations/conf.d/team.yaml": `
+tools:
+  - name: describe
+    command: [kubectl, describe]
+    params:
+      pod: {$ref: "#/definitions/params/pod"}
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	// Included and conf.d files use definitions of the main file, and the
+	// other way round
+	if len(cfg.Tools) != 3 {
+		t.Fatalf("Expected 3 tools, got %d", len(cfg.Tools))
+	}
+	if len(cfg.Tools[0].Params["namespace"].Validate) != 1 {
+		t.Errorf("Expected validation from extra.yaml, got %v", cfg.Tools[0].Params["namespace"].Validate)
+	}
+	for _, tool := range cfg.Tools[1:] {
+		if tool.Params["pod"].Description != "The pod" {
+			t.Errorf("Expected pod parameter of %s from config.yaml, got %+v", tool.Name, tool.Params["pod"])
+		}
+	}
+	if cfg.Definitions == nil || len(cfg.Definitions.Params) != 1 || len(cfg.Definitions.Validations) != 1 {
+		t.Errorf("Expected definitions of both files, got %+v", cfg.Definitions)
+	}
+
+	// A name may be defined by only one file
+	writeFiles(t, tempDir, map[string]string{
+		"extra.yaml": `
+definitions:
+  params:
+    pod:
+      type: string
+`,
+	})
+	_, err = LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "definition params/pod is already defined in") {
+		t.Errorf("Expected duplicate definition error, got %v", err)
+	}
+}
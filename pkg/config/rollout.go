@@ -0,0 +1,93 @@
+package config
+
+import "strings"
+
+// Rollout runs a tool on several targets in batches instead of all at once
+type Rollout struct {
+	// BatchSize is the number of targets in each batch
+	BatchSize int `yaml:"batch_size,omitempty"`
+	// BatchPercent is the size of each batch as a percentage of the targets
+	BatchPercent int `yaml:"batch_percent,omitempty"`
+	// Pause is the time to wait between batches
+	Pause int `yaml:"pause,omitempty"` // in seconds
+	// HealthCheck is the tool that must succeed on every target of a batch
+	// before the next batch starts, such as "systemctl_status"
+	HealthCheck string `yaml:"health_check,omitempty"`
+}
+
+// validateRollouts validates the rollout settings of tools and subtools
+func (c *Config) validateRollouts() []Issue {
+	var issues []Issue
+	for _, tool := range c.Tools {
+		issues = append(issues, c.validateRollout(tool.Rollout, "tool "+tool.Name, tool.Pos)...)
+		issues = append(issues, c.validateSubtoolRollouts(tool.Subtools, tool.Name)...)
+	}
+	return issues
+}
+
+// validateSubtoolRollouts validates the rollout settings of subtools recursively
+func (c *Config) validateSubtoolRollouts(subtools []Subtool, parentName string) []Issue {
+	var issues []Issue
+	for _, subtool := range subtools {
+		fullName := parentName + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+		issues = append(issues, c.validateRollout(subtool.Rollout, "subtool "+fullName, subtool.Pos)...)
+		if subtool.TTY && subtool.Rollout != nil {
+			issues = append(issues, newIssue(subtool.Pos, "subtool %s with tty cannot be rolled out", fullName))
+		}
+		issues = append(issues, c.validateSubtoolRollouts(subtool.Subtools, fullName)...)
+	}
+	return issues
+}
+
+// validateRollout validates the rollout settings of one tool
+func (c *Config) validateRollout(rollout *Rollout, owner string, pos Position) []Issue {
+	if rollout == nil {
+		return nil
+	}
+
+	var issues []Issue
+	if rollout.BatchSize > 0 && rollout.BatchPercent > 0 {
+		issues = append(issues, newIssue(pos, "rollout of %s sets both batch_size and batch_percent", owner))
+	}
+	if rollout.BatchSize < 0 || rollout.Pause < 0 {
+		issues = append(issues, newIssue(pos, "rollout settings of %s must not be negative", owner))
+	}
+	if rollout.BatchPercent < 0 || rollout.BatchPercent > 100 {
+		issues = append(issues, newIssue(pos, "batch_percent of %s must be between 1 and 100", owner))
+	}
+	if rollout.HealthCheck != "" && !c.hasToolPath(rollout.HealthCheck) {
+		issues = append(issues, newIssue(pos, "rollout of %s refers to unknown health check tool %s", owner, rollout.HealthCheck))
+	}
+	return issues
+}
+
+// hasToolPath reports whether a tool path such as "systemctl_status" refers
+// to a tool or one of its subtools, by name or alias
+func (c *Config) hasToolPath(path string) bool {
+	first, rest, hasRest := strings.Cut(path, "_")
+	for _, tool := range c.Tools {
+		if !matchesToolName(first, tool.Name, tool.Aliases) {
+			continue
+		}
+		if !hasRest {
+			return true
+		}
+		for _, subtool := range tool.Subtools {
+			if matchesToolName(rest, subtool.Name, subtool.Aliases) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesToolName reports whether a path part is the name or an alias of a
+// tool or subtool. Spaces in names and aliases match underscores.
+func matchesToolName(part, name string, aliases []string) bool {
+	for _, candidate := range append([]string{name}, aliases...) {
+		if strings.ReplaceAll(candidate, " ", "_") == part {
+			return true
+		}
+	}
+	return false
+}
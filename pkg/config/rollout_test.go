@@ -0,0 +1,72 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigRollout(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `version: 2
+tools:
+  - name: systemctl
+    command: [systemctl]
+    subtools:
+      - name: restart
+        args: [restart]
+        rollout:
+          batch_percent: 25
+          pause: 30
+          health_check: systemctl_status
+      - name: status
+        args: [is-active]
+      - name: stop
+        args: [stop]
+        rollout:
+          batch_size: 2
+          batch_percent: 150
+          health_check: systemctl_missing
+      - name: reload
+        args: [reload]
+        rollout:
+          pause: -1
+      - name: top
+        args: [status]
+        tty: true
+        rollout:
+          batch_size: 1
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	rollout := cfg.Tools[0].Subtools[0].Rollout
+	if rollout == nil || rollout.BatchPercent != 25 || rollout.Pause != 30 || rollout.HealthCheck != "systemctl_status" {
+		t.Errorf("Unexpected rollout: %+v", rollout)
+	}
+
+	expected := []string{
+		"rollout of subtool systemctl_stop sets both batch_size and batch_percent",
+		"batch_percent of subtool systemctl_stop must be between 1 and 100",
+		"rollout of subtool systemctl_stop refers to unknown health check tool systemctl_missing",
+		"rollout settings of subtool systemctl_reload must not be negative",
+		"subtool systemctl_top with tty cannot be rolled out",
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation errors")
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "systemctl_restart") {
+		t.Errorf("Expected no error for systemctl_restart, got:\n%v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the identifier of the configuration JSON Schema
+const SchemaID = "https://raw.githubusercontent.com/takutakahashi/operation-mcp/main/docs/config.schema.json"
+
+// schemaEnums lists the allowed values of fields, keyed by "Type.field" with
+// the type declaring the field, which also applies them where it is inlined
+var schemaEnums = map[string][]string{
+	"Action.type":               {"confirm", "timeout", "force"},
+	"Parameter.from_context":    {ContextKubeContext, ContextKubeNamespace},
+	"SSHConfig.host_key_policy": {HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyInsecure},
+	"Transfer.direction":        {TransferUpload, TransferDownload},
+}
+
+// Schema returns a JSON Schema describing the configuration file. It is
+// generated from the Config type and its yaml tags.
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: make(map[string]interface{})}
+	root := g.structSchema(reflect.TypeOf(Config{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SchemaID
+	root["title"] = "operations configuration"
+	root["$defs"] = g.defs
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// schemaGenerator builds schemas for Go types, collecting named structs in defs
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// typeSchema returns the schema for a Go type
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(Args{}):
+		// Args elements are argument templates or arg groups
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"oneOf": []interface{}{
+							map[string]interface{}{"type": "string"},
+							g.typeSchema(reflect.TypeOf(ArgGroup{})),
+						},
+					},
+				},
+				refSchema(),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		// Like mappings, lists can be replaced by a reference to a definition
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())},
+				refSchema(),
+			},
+		}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		// Named structs are shared through $defs, which also allows recursion
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema for a struct type
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, field := range schemaFields(t) {
+		schema := g.typeSchema(field.Type)
+		if enum, ok := schemaEnums[declaringType(t, field).Name()+"."+yamlName(field)]; ok {
+			schema["enum"] = enum
+		}
+		properties[yamlName(field)] = schema
+	}
+
+	// Any mapping can be replaced by a reference to a definition
+	if t != reflect.TypeOf(Config{}) {
+		properties[RefKey] = map[string]interface{}{"type": "string"}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// refSchema returns the schema of a mapping that is only a reference
+func refSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           map[string]interface{}{RefKey: map[string]interface{}{"type": "string"}},
+		"required":             []string{RefKey},
+		"additionalProperties": false,
+	}
+}
+
+// declaringType returns the struct that declares a field of t, which is
+// another struct when the field comes from an inlined one
+func declaringType(t reflect.Type, field reflect.StructField) reflect.Type {
+	found, ok := t.FieldByName(field.Name)
+	if !ok {
+		return t
+	}
+	for _, i := range found.Index[:len(found.Index)-1] {
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// schemaFields returns the fields of a struct that appear in the config file
+func schemaFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || yamlName(field) == "-" {
+			continue
+		}
+		// Inline structs contribute their fields to the parent mapping
+		if field.Anonymous && strings.Contains(field.Tag.Get("yaml"), ",inline") {
+			fields = append(fields, schemaFields(field.Type)...)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// yamlName returns the key of a struct field in the config file
+func yamlName(field reflect.StructField) string {
+	tag := field.Tag.Get("yaml")
+	if tag == "" {
+		return strings.ToLower(field.Name)
+	}
+	return strings.Split(tag, ",")[0]
+}
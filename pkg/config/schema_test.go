@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaPath is the published schema, regenerated with `make schema`
+const schemaPath = "../../docs/config.schema.json"
+
+func TestSchemaUpToDate(t *testing.T) {
+	schema, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+
+	published, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatalf("Failed to read published schema: %v", err)
+	}
+
+	if strings.TrimSpace(string(published)) != strings.TrimSpace(string(schema)) {
+		t.Errorf("%s is out of date; run `make schema`", schemaPath)
+	}
+}
+
+func TestSchemaCoversStructs(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	// Every field of every config struct must be described in the schema
+	types := []reflect.Type{
+		reflect.TypeOf(Action{}),
+		reflect.TypeOf(Tool{}),
+		reflect.TypeOf(Subtool{}),
+		reflect.TypeOf(ArgGroup{}),
+		reflect.TypeOf(Parameter{}),
+		reflect.TypeOf(Validation{}),
+		reflect.TypeOf(SSHConfig{}),
+		reflect.TypeOf(Definitions{}),
+		reflect.TypeOf(Profile{}),
+	}
+	for _, field := range schemaFields(reflect.TypeOf(Config{})) {
+		if _, ok := schema.Properties[yamlName(field)]; !ok {
+			t.Errorf("Schema is missing Config.%s", yamlName(field))
+		}
+	}
+	for _, typ := range types {
+		def, ok := schema.Defs[typ.Name()]
+		if !ok {
+			t.Errorf("Schema is missing definition for %s", typ.Name())
+			continue
+		}
+		for _, field := range schemaFields(typ) {
+			if _, ok := def.Properties[yamlName(field)]; !ok {
+				t.Errorf("Schema is missing %s.%s", typ.Name(), yamlName(field))
+			}
+		}
+	}
+
+	// Subtools are recursive
+	subtools, _ := json.Marshal(schema.Defs["Subtool"].Properties["subtools"])
+	if !strings.Contains(string(subtools), `"#/$defs/Subtool"`) {
+		t.Errorf("Expected subtools to reference the Subtool definition, got %s", subtools)
+	}
+}
+
+func TestSchemaAcceptsExamples(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	examples := map[string]string{
+		"refs": refExample,
+		"target": `
+targets:
+  web1:
+    host: web1.example.com
+    host_key_policy: tofu
+    labels: {role: web}
+`,
+	}
+	for name, example := range examples {
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(example), &doc); err != nil {
+			t.Fatalf("Failed to parse %s example: %v", name, err)
+		}
+		if err := validateSchema(schema, schema, doc, ""); err != nil {
+			t.Errorf("Expected %s example to match the schema, got: %v", name, err)
+		}
+	}
+
+	// Enums of inlined fields apply to the struct inlining them
+	var doc interface{}
+	yaml.Unmarshal([]byte("targets: {web1: {host: web1, host_key_policy: trust}}"), &doc)
+	if err := validateSchema(schema, schema, doc, ""); err == nil || !strings.Contains(err.Error(), "/targets/web1/host_key_policy") {
+		t.Errorf("Expected host_key_policy of a target to be checked, got: %v", err)
+	}
+}
+
+// validateSchema checks a decoded document against the subset of JSON Schema
+// that Schema generates
+func validateSchema(root, schema map[string]interface{}, value interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		return validateSchema(root, def, value, path)
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		var errs []string
+		for _, option := range oneOf {
+			err := validateSchema(root, option.(map[string]interface{}), value, path)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s matches no option: %s", path, strings.Join(errs, "; "))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || allowed == value
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", path, value)
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if _, ok := object[key.(string)]; !ok {
+					return fmt.Errorf("%s: missing %s", path, key)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, child := range object {
+			childSchema, ok := properties[key].(map[string]interface{})
+			if !ok {
+				additional, ok := schema["additionalProperties"].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s: unexpected key %s", path, key)
+				}
+				childSchema = additional
+			}
+			if err := validateSchema(root, childSchema, child, path+"/"+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", path, value)
+		}
+		for i, item := range array {
+			if err := validateSchema(root, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", path, value)
+		}
+	case "integer":
+		if _, ok := value.(int); !ok {
+			return fmt.Errorf("%s: expected an integer, got %T", path, value)
+		}
+	case "number":
+		switch value.(type) {
+		case int, float64:
+		default:
+			return fmt.Errorf("%s: expected a number, got %T", path, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalTarget is the target name that runs tools on the local machine
+const LocalTarget = "local"
+
+// Host key policies
+const (
+	HostKeyPolicyStrict   = "strict"
+	HostKeyPolicyTOFU     = "tofu"
+	HostKeyPolicyInsecure = "insecure"
+)
+
+// Target is a named remote host that tools run on
+type Target struct {
+	SSHConfig `yaml:",inline"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+	Pos       Position          `yaml:"-"`
+}
+
+// FanOutConfig sets how a tool runs when several targets are selected
+type FanOutConfig struct {
+	// Concurrency is the maximum number of targets running at once
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// FailFast stops starting new targets after the first failure
+	FailFast bool `yaml:"fail_fast,omitempty"`
+	// MaxFailures stops starting new targets after this many failures
+	MaxFailures int `yaml:"max_failures,omitempty"`
+	// Timeout is the maximum time a tool may run on one target
+	Timeout int `yaml:"timeout,omitempty"` // in seconds
+}
+
+// UnmarshalYAML decodes a target and records its position
+func (t *Target) UnmarshalYAML(value *yaml.Node) error {
+	type plain Target
+	if err := value.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.Pos = nodePosition(value)
+	return nil
+}
+
+// validateTargets validates the targets and the target references of tools
+func (c *Config) validateTargets() []Issue {
+	var issues []Issue
+	for _, name := range sortedTargetNames(c.Targets) {
+		target := c.Targets[name]
+		if name == LocalTarget {
+			issues = append(issues, newIssue(target.Pos, "target name %s is reserved for local execution", LocalTarget))
+		}
+		if target.Host == "" {
+			issues = append(issues, newIssue(target.Pos, "target %s missing host", name))
+		}
+		issues = append(issues, validateSSHConfig(target.SSHConfig, "target "+name, target.Pos)...)
+	}
+	if c.SSH != nil {
+		issues = append(issues, validateSSHConfig(*c.SSH, "ssh", Position{File: c.mainFile()})...)
+	}
+
+	if c.FanOut != nil {
+		if c.FanOut.Concurrency < 0 || c.FanOut.MaxFailures < 0 || c.FanOut.Timeout < 0 {
+			issues = append(issues, newIssue(Position{File: c.mainFile()}, "fanout settings must not be negative"))
+		}
+	}
+
+	for _, tool := range c.Tools {
+		issues = append(issues, c.validateTargetRefs(tool.Target, tool.Targets, "tool "+tool.Name, tool.Pos)...)
+		issues = append(issues, c.validateSubtoolTargets(tool.Subtools, tool.Name)...)
+	}
+	return issues
+}
+
+// validateSubtoolTargets validates the target references of subtools recursively
+func (c *Config) validateSubtoolTargets(subtools []Subtool, parentName string) []Issue {
+	var issues []Issue
+	for _, subtool := range subtools {
+		fullName := parentName + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+		issues = append(issues, c.validateTargetRefs(subtool.Target, subtool.Targets, "subtool "+fullName, subtool.Pos)...)
+		issues = append(issues, c.validateSubtoolTargets(subtool.Subtools, fullName)...)
+	}
+	return issues
+}
+
+// validateTargetRefs checks that the targets a tool refers to exist
+func (c *Config) validateTargetRefs(target string, targets []string, owner string, pos Position) []Issue {
+	var issues []Issue
+	for _, name := range append([]string{target}, targets...) {
+		if name == "" || name == LocalTarget {
+			continue
+		}
+		if _, ok := c.Targets[name]; !ok {
+			issues = append(issues, newIssue(pos, "%s refers to unknown target %s", owner, name))
+		}
+	}
+	if target != "" && len(targets) > 0 && !containsString(targets, target) {
+		issues = append(issues, newIssue(pos, "target %s of %s is not one of its targets", target, owner))
+	}
+	return issues
+}
+
+// validateSSHConfig validates the host key settings and the jump hosts of an
+// SSH connection
+func validateSSHConfig(ssh SSHConfig, owner string, pos Position) []Issue {
+	issues := validateHostKey(ssh, owner, pos)
+	for i, hop := range ssh.ProxyJump {
+		hopOwner := fmt.Sprintf("jump host %d of %s", i+1, owner)
+		if hop.Host == "" {
+			issues = append(issues, newIssue(pos, "%s missing host", hopOwner))
+		}
+		if len(hop.ProxyJump) > 0 {
+			issues = append(issues, newIssue(pos, "%s must not set proxy_jump; list every hop in order instead", hopOwner))
+		}
+		issues = append(issues, validateHostKey(hop, hopOwner, pos)...)
+	}
+	return issues
+}
+
+// validateHostKey validates the host key settings of an SSH connection
+func validateHostKey(ssh SSHConfig, owner string, pos Position) []Issue {
+	var issues []Issue
+	switch ssh.HostKeyPolicy {
+	case "", HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyInsecure:
+	default:
+		issues = append(issues, newIssue(pos, "invalid host_key_policy %s for %s; must be %s, %s or %s",
+			ssh.HostKeyPolicy, owner, HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyInsecure))
+	}
+	if ssh.VerifyHost != nil && !*ssh.VerifyHost && (ssh.HostKeyPolicy == HostKeyPolicyStrict || ssh.HostKeyPolicy == HostKeyPolicyTOFU) {
+		issues = append(issues, newIssue(pos, "%s sets verify_host: false with host_key_policy: %s", owner, ssh.HostKeyPolicy))
+	}
+	if ssh.HostKeyFingerprint != "" && ssh.HostKeyPolicy == HostKeyPolicyInsecure {
+		issues = append(issues, newIssue(pos, "%s pins host_key_fingerprint with host_key_policy: %s", owner, HostKeyPolicyInsecure))
+	}
+	return issues
+}
+
+// sortedTargetNames returns the names of targets in sorted order
+func sortedTargetNames(targets map[string]Target) []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mainFile returns the main config file, or "" when unknown
+func (c *Config) mainFile() string {
+	if len(c.Files) == 0 {
+		return ""
+	}
+	return c.Files[0]
+}
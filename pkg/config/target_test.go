@@ -0,0 +1,99 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigTargets(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `version: 2
+targets:
+  bastion:
+    host: bastion.example.com
+    user: ops
+    labels:
+      role: bastion
+  local:
+    host: localhost
+  nohost:
+    user: ops
+  pinned:
+    host: pinned.internal
+    host_key_policy: insecure
+    host_key_fingerprint: SHA256:abc
+    proxy_jump:
+      - host: bastion.example.com
+        host_key_policy: trusting
+  app1:
+    host: app1.internal
+    host_key_policy: tofu
+    verify_host: false
+    proxy_jump:
+      - host: bastion.example.com
+        user: jump
+        key: ~/.ssh/jump
+      - user: ops
+        proxy_jump:
+          - host: other.example.com
+fanout:
+  concurrency: 4
+  max_failures: -1
+tools:
+  - name: kubectl
+    target: bastion
+    command: [kubectl]
+    subtools:
+      - name: get
+        targets: [app9]
+        args: [get]
+  - name: systemctl
+    target: bastion
+    targets: [nohost]
+    command: [systemctl]
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	bastion := cfg.Targets["bastion"]
+	if bastion.Host != "bastion.example.com" || bastion.User != "ops" || bastion.Labels["role"] != "bastion" {
+		t.Errorf("Unexpected target: %+v", bastion)
+	}
+	if jumps := cfg.Targets["app1"].ProxyJump; len(jumps) != 2 || jumps[0].Host != "bastion.example.com" || jumps[0].User != "jump" {
+		t.Errorf("Unexpected jump hosts: %+v", jumps)
+	}
+	if cfg.Tools[0].Target != "bastion" || cfg.Tools[0].Subtools[0].Targets[0] != "app9" {
+		t.Errorf("Expected tool targets to be loaded")
+	}
+	if cfg.FanOut == nil || cfg.FanOut.Concurrency != 4 {
+		t.Errorf("Expected fanout settings to be loaded, got %+v", cfg.FanOut)
+	}
+
+	expected := []string{
+		"target name local is reserved for local execution",
+		"target nohost missing host",
+		"subtool kubectl_get refers to unknown target app9",
+		"target bastion of tool systemctl is not one of its targets",
+		"fanout settings must not be negative",
+		"jump host 2 of target app1 missing host",
+		"jump host 2 of target app1 must not set proxy_jump",
+		"target app1 sets verify_host: false with host_key_policy: tofu",
+		"target pinned pins host_key_fingerprint with host_key_policy: insecure",
+		"invalid host_key_policy trusting for jump host 1 of target pinned",
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation errors")
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// TemplateFuncs are the names of the functions the tool package provides to
+// templates, on top of the text/template builtins
+var TemplateFuncs = []string{
+	OmitFunc, "optional", "default", "lower", "upper", "trim", "join", "split",
+	"replace", "quote", "toJson", "b64enc", "env", "now", "date",
+}
+
+// builtinFuncs are the functions predefined by text/template
+var builtinFuncs = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print",
+	"printf", "println", "urlquery", "eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// templateFuncs returns every function name a template may call, in the form
+// expected by parse.Tree.Parse. The parser only checks that a name maps to a
+// non-nil value, so the functions themselves are not needed.
+func templateFuncs() map[string]interface{} {
+	funcs := make(map[string]interface{}, len(TemplateFuncs)+len(builtinFuncs))
+	for _, name := range append(append([]string{}, TemplateFuncs...), builtinFuncs...) {
+		funcs[name] = struct{}{}
+	}
+	return funcs
+}
+
+// templateScope tracks the parameters visible to a tool or subtool
+type templateScope struct {
+	path     string
+	declared map[string]*declaredParam
+}
+
+// declaredParam is a parameter declaration and whether any template uses it
+type declaredParam struct {
+	owner string
+	pos   Position
+	used  bool
+}
+
+// CheckTemplates parses every template in tool commands, subtool args,
+// working directories, environment variables and transfer paths and checks
+// the parameters they reference. References to undeclared parameters
+// and syntax errors are errors; declared parameters that are never referenced
+// are warnings.
+func (c *Config) CheckTemplates() []Issue {
+	var issues []Issue
+
+	for _, tool := range c.Tools {
+		// Collect every parameter declared anywhere in the tool, to point out
+		// references to parameters that are only declared on a sibling
+		declaredAnywhere := make(map[string][]string)
+		collectDeclared(tool.Name, tool.Params, tool.Subtools, declaredAnywhere)
+
+		scope := &templateScope{path: tool.Name, declared: make(map[string]*declaredParam)}
+		var own []*declaredParam
+		for _, name := range sortedParamNames(tool.Params) {
+			param := &declaredParam{owner: tool.Name, pos: tool.Params[name].Pos}
+			scope.declared[name] = param
+			own = append(own, param)
+		}
+
+		for i, arg := range tool.Command {
+			issues = append(issues, checkTemplate(arg, scope, elementPosition(tool.commandPos, i, tool.Pos), declaredAnywhere)...)
+		}
+		issues = append(issues, checkEnvironmentTemplates(tool.Workdir, tool.Env, scope, tool.Pos, declaredAnywhere)...)
+
+		for _, subtool := range tool.Subtools {
+			issues = append(issues, checkSubtoolTemplates(subtool, scope, declaredAnywhere)...)
+		}
+
+		issues = append(issues, unusedParams(tool.Params, own)...)
+	}
+
+	return issues
+}
+
+// checkSubtoolTemplates checks the args of a subtool and its nested subtools
+func checkSubtoolTemplates(subtool Subtool, parent *templateScope, declaredAnywhere map[string][]string) []Issue {
+	var issues []Issue
+
+	path := parent.path + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+	scope := &templateScope{path: path, declared: make(map[string]*declaredParam)}
+	for name, param := range parent.declared {
+		scope.declared[name] = param
+	}
+
+	// Parameters declared on the subtool override inherited ones
+	var own []*declaredParam
+	for _, name := range sortedParamNames(subtool.Params) {
+		param := &declaredParam{owner: path, pos: subtool.Params[name].Pos}
+		scope.declared[name] = param
+		own = append(own, param)
+	}
+
+	for i, arg := range subtool.Args {
+		issues = append(issues, checkTemplate(arg, scope, elementPosition(subtool.argPos, i, subtool.Pos), declaredAnywhere)...)
+	}
+	issues = append(issues, checkEnvironmentTemplates(subtool.Workdir, subtool.Env, scope, subtool.Pos, declaredAnywhere)...)
+	if subtool.Transfer != nil {
+		issues = append(issues, checkTemplate(subtool.Transfer.Source, scope, subtool.Pos, declaredAnywhere)...)
+		issues = append(issues, checkTemplate(subtool.Transfer.Destination, scope, subtool.Pos, declaredAnywhere)...)
+	}
+
+	for _, nested := range subtool.Subtools {
+		issues = append(issues, checkSubtoolTemplates(nested, scope, declaredAnywhere)...)
+	}
+
+	return append(issues, unusedParams(subtool.Params, own)...)
+}
+
+// checkEnvironmentTemplates checks the working directory and the environment
+// variables of a tool or subtool
+func checkEnvironmentTemplates(workdir string, env Env, scope *templateScope, pos Position, declaredAnywhere map[string][]string) []Issue {
+	issues := checkTemplate(workdir, scope, pos, declaredAnywhere)
+	for _, name := range env.Names() {
+		issues = append(issues, checkTemplate(env[name], scope, pos, declaredAnywhere)...)
+	}
+	return issues
+}
+
+// elementPosition returns the position of the i-th list element, or the
+// fallback when element positions are unknown
+func elementPosition(positions []Position, i int, fallback Position) Position {
+	if i < len(positions) {
+		return positions[i]
+	}
+	return fallback
+}
+
+// unusedParams reports declared parameters that no template referenced
+func unusedParams(params Parameters, own []*declaredParam) []Issue {
+	var issues []Issue
+	for i, name := range sortedParamNames(params) {
+		if !own[i].used {
+			issues = append(issues, Issue{
+				Pos:     own[i].pos,
+				Message: fmt.Sprintf("parameter %s in %s is never used", name, own[i].owner),
+				Warning: true,
+			})
+		}
+	}
+	return issues
+}
+
+// checkTemplate parses a single template and checks its parameter references
+func checkTemplate(text string, scope *templateScope, pos Position, declaredAnywhere map[string][]string) []Issue {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+
+	tree := parse.New("arg")
+	if _, err := tree.Parse(text, "", "", make(map[string]*parse.Tree), templateFuncs()); err != nil {
+		return []Issue{{
+			Pos:     pos,
+			Message: fmt.Sprintf("invalid template %q in %s: %v", text, scope.path, err),
+		}}
+	}
+
+	var issues []Issue
+	for _, name := range templateReferences(tree.Root) {
+		if param, ok := scope.declared[name]; ok {
+			param.used = true
+			continue
+		}
+
+		message := fmt.Sprintf("template %q in %s references undeclared parameter %s", text, scope.path, name)
+		if owners := declaredAnywhere[name]; len(owners) > 0 {
+			message += fmt.Sprintf(" (declared on %s)", strings.Join(owners, ", "))
+		}
+		issues = append(issues, Issue{Pos: pos, Message: message})
+	}
+
+	return issues
+}
+
+// collectDeclared records which tools and subtools declare each parameter
+func collectDeclared(path string, params Parameters, subtools []Subtool, result map[string][]string) {
+	for _, name := range sortedParamNames(params) {
+		result[name] = append(result[name], path)
+	}
+	for _, subtool := range subtools {
+		collectDeclared(path+"_"+strings.ReplaceAll(subtool.Name, " ", "_"), subtool.Params, subtool.Subtools, result)
+	}
+}
+
+// sortedParamNames returns parameter names in a stable order
+func sortedParamNames(params Parameters) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateReferences returns the parameter names referenced by a template.
+// Inside range and with blocks dot is rebound, so only $.name references are
+// counted there.
+func templateReferences(root *parse.ListNode) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			refs = append(refs, name)
+		}
+	}
+
+	var walk func(node parse.Node, dotIsRoot bool)
+	walk = func(node parse.Node, dotIsRoot bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, dotIsRoot)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, dotIsRoot)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, dotIsRoot)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, dotIsRoot)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, dotIsRoot)
+		case *parse.FieldNode:
+			if dotIsRoot && len(n.Ident) > 0 {
+				add(n.Ident[0])
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				add(n.Ident[1])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, dotIsRoot)
+			walk(n.List, dotIsRoot)
+			walk(n.ElseList, dotIsRoot)
+		case *parse.RangeNode:
+			walk(n.Pipe, dotIsRoot)
+			walk(n.List, false)
+			walk(n.ElseList, dotIsRoot)
+		case *parse.WithNode:
+			walk(n.Pipe, dotIsRoot)
+			walk(n.List, false)
+			walk(n.ElseList, dotIsRoot)
+		case *parse.TemplateNode:
+			walk(n.Pipe, dotIsRoot)
+		}
+	}
+	walk(root, true)
+
+	return refs
+}
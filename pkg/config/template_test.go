@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+tools:
+  - name: kubectl
+    command: [kubectl]
+    params:
+      namespace:
+        type: string
+      unused:
+        type: string
+    subtools:
+      - name: describe pod
+        params:
+          pod:
+            type: string
+        args: ["describe", "pod", "{{.pod}}", "-n", "{{.namespace}}"]
+      - name: logs
+        args: ["logs", "{{.pod}}", "-n", "{{.namspace}}"]
+      - name: broken
+        args: ["{{.namespace"]
+      - name: typo
+        args: ["{{upper .namespace}}", "{{uper .namespace}}"]
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	issues := cfg.CheckTemplates()
+
+	var errors, warnings []Issue
+	for _, issue := range issues {
+		if issue.Warning {
+			warnings = append(warnings, issue)
+		} else {
+			errors = append(errors, issue)
+		}
+	}
+
+	if len(errors) != 4 {
+		t.Fatalf("Expected 4 errors, got %d: %v", len(errors), errors)
+	}
+
+	// Parameter only declared on a sibling subtool
+	if !strings.Contains(errors[0].Message, "undeclared parameter pod") ||
+		!strings.Contains(errors[0].Message, "declared on kubectl_describe_pod") {
+		t.Errorf("Expected sibling declaration error, got: %v", errors[0])
+	}
+	if errors[0].Pos.File != configPath || errors[0].Pos.Line != 17 || errors[0].Pos.Column != 24 {
+		t.Errorf("Expected position %s:17:24, got %v", configPath, errors[0].Pos)
+	}
+
+	// Typo in parameter name
+	if !strings.Contains(errors[1].Message, "undeclared parameter namspace") {
+		t.Errorf("Expected undeclared parameter error, got: %v", errors[1])
+	}
+
+	// Syntax error
+	if !strings.Contains(errors[2].Message, "invalid template") {
+		t.Errorf("Expected invalid template error, got: %v", errors[2])
+	}
+
+	// Unknown function
+	if !strings.Contains(errors[3].Message, `function "uper" not defined`) {
+		t.Errorf("Expected undefined function error, got: %v", errors[3])
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "parameter unused in kubectl is never used") {
+		t.Errorf("Expected warning for unused parameter, got: %v", warnings)
+	}
+
+	// Validate reports the first error
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "undeclared parameter pod") {
+		t.Errorf("Expected Validate to fail with template error, got: %v", err)
+	}
+}
+
+func TestCheckTemplatesScopes(t *testing.T) {
+	cfg := &Config{
+		Tools: []Tool{
+			{
+				Name:    "echo",
+				Command: []string{"echo"},
+				Params: map[string]Parameter{
+					"items": {Type: "string"},
+					"sep":   {Type: "string"},
+				},
+				Subtools: []Subtool{
+					{
+						Name: "each",
+						Args: []string{
+							`{{range split "," .items}}{{.}}{{$.sep}}{{end}}`,
+							`{{if .sep}}-s{{else}}{{omit}}{{end}}`,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	issues := cfg.CheckTemplates()
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues, got: %v", issues)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import "strings"
+
+// Transfer directions
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+// DefaultMaxTransferSize is the size limit of transfers without max_size
+const DefaultMaxTransferSize = 100 << 20
+
+// Transfer makes a subtool copy a file between the local machine and its
+// target instead of running a command
+type Transfer struct {
+	// Direction is upload, from the local machine to the target, or download
+	Direction string `yaml:"direction"`
+	// Source and Destination are the paths of the files, which may use
+	// templates like args
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+	// MaxSize is the largest file size allowed
+	MaxSize int64 `yaml:"max_size,omitempty"` // in bytes
+}
+
+// validateTransfers validates the transfer settings of subtools
+func (c *Config) validateTransfers() []Issue {
+	var issues []Issue
+	for _, tool := range c.Tools {
+		issues = append(issues, validateSubtoolTransfers(tool.Subtools, tool.Name)...)
+	}
+	return issues
+}
+
+// validateSubtoolTransfers validates the transfer settings of subtools
+// recursively
+func validateSubtoolTransfers(subtools []Subtool, parentName string) []Issue {
+	var issues []Issue
+	for _, subtool := range subtools {
+		fullName := parentName + "_" + strings.ReplaceAll(subtool.Name, " ", "_")
+		if subtool.Transfer != nil {
+			issues = append(issues, validateTransfer(subtool, fullName)...)
+		}
+		issues = append(issues, validateSubtoolTransfers(subtool.Subtools, fullName)...)
+	}
+	return issues
+}
+
+// validateTransfer validates the transfer settings of one subtool
+func validateTransfer(subtool Subtool, fullName string) []Issue {
+	transfer, pos := subtool.Transfer, subtool.Pos
+
+	var issues []Issue
+	if transfer.Direction != TransferUpload && transfer.Direction != TransferDownload {
+		issues = append(issues, newIssue(pos, "transfer of subtool %s must have direction %s or %s", fullName, TransferUpload, TransferDownload))
+	}
+	if transfer.Source == "" {
+		issues = append(issues, newIssue(pos, "transfer of subtool %s missing source", fullName))
+	}
+	if transfer.Destination == "" {
+		issues = append(issues, newIssue(pos, "transfer of subtool %s missing destination", fullName))
+	}
+	if transfer.MaxSize < 0 {
+		issues = append(issues, newIssue(pos, "max_size of subtool %s must not be negative", fullName))
+	}
+	if len(subtool.Args) > 0 {
+		issues = append(issues, newIssue(pos, "subtool %s sets both args and transfer", fullName))
+	}
+	if subtool.Workdir != "" || len(subtool.Env) > 0 || subtool.Sudo != nil || subtool.TTY {
+		issues = append(issues, newIssue(pos, "workdir, env, sudo and tty do not apply to transfer subtool %s", fullName))
+	}
+	if subtool.Rollout != nil {
+		issues = append(issues, newIssue(pos, "transfer subtool %s cannot be rolled out", fullName))
+	}
+	return issues
+}
+
+// transfersOnly reports whether a tool only has transfer subtools, in which
+// case it needs no command
+func transfersOnly(tool Tool) bool {
+	for _, subtool := range tool.Subtools {
+		if subtool.Transfer == nil {
+			return false
+		}
+	}
+	return len(tool.Subtools) > 0
+}
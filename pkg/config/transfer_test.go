@@ -0,0 +1,80 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigTransfer(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFiles(t, tempDir, map[string]string{
+		"config.yaml": `version: 2
+tools:
+  - name: files
+    subtools:
+      - name: push
+        transfer:
+          direction: upload
+          source: ./{{.site}}.conf
+          destination: /etc/nginx/sites-available/{{.site}}.conf
+          max_size: 65536
+        danger_level: high
+        params:
+          site:
+            type: string
+            required: true
+      - name: pull
+        transfer:
+          direction: fetch
+          source: /var/log/app.log
+  - name: logs
+    command: [tail]
+    subtools:
+      - name: copy
+        args: [-f]
+        transfer:
+          direction: download
+          source: /var/log/{{.name}}.log
+          destination: ./app.log
+          max_size: -1
+        sudo:
+          user: root
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	transfer := cfg.Tools[0].Subtools[0].Transfer
+	if transfer == nil || transfer.Direction != TransferUpload || transfer.MaxSize != 65536 {
+		t.Errorf("Unexpected transfer: %+v", transfer)
+	}
+
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation errors")
+	}
+	expected := []string{
+		"transfer of subtool files_pull must have direction upload or download",
+		"transfer of subtool files_pull missing destination",
+		"max_size of subtool logs_copy must not be negative",
+		"subtool logs_copy sets both args and transfer",
+		"workdir, env, sudo and tty do not apply to transfer subtool logs_copy",
+		`template "/var/log/{{.name}}.log" in logs_copy references undeclared parameter name`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error %q, got:\n%v", want, err)
+		}
+	}
+
+	// A tool with only transfer subtools needs no command
+	for _, unexpected := range []string{"tool files missing command", "files_push"} {
+		if strings.Contains(err.Error(), unexpected) {
+			t.Errorf("Unexpected error %q in:\n%v", unexpected, err)
+		}
+	}
+}
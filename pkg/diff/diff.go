@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 3
+
+// edit is one line of an edit script. Op is ' ' for an unchanged line, '-'
+// for a deleted line and '+' for an inserted line.
+type edit struct {
+	op   byte
+	line string
+}
+
+// Unified returns a unified diff of two texts, or "" when they are equal
+func Unified(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+
+	// oldLine and newLine are the line numbers of edits[i]
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		begin, end := hunk(edits, i)
+		oldStart, newStart := oldLine-(i-begin), newLine-(i-begin)
+		oldCount, newCount := 0, 0
+		for _, e := range edits[begin:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, e := range edits[begin:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			out.WriteByte('\n')
+		}
+
+		for _, e := range edits[i:end] {
+			if e.op != '+' {
+				oldLine++
+			}
+			if e.op != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunk returns the edits shown in the hunk starting with the change at
+// edits[start]: up to Context unchanged lines before it, and every later
+// change separated by at most 2*Context unchanged lines, followed by up to
+// Context unchanged lines
+func hunk(edits []edit, start int) (begin, end int) {
+	begin = start
+	for begin > 0 && start-begin < Context && edits[begin-1].op == ' ' {
+		begin--
+	}
+
+	end = start
+	for end < len(edits) {
+		if edits[end].op != ' ' {
+			end++
+			continue
+		}
+		run := end
+		for run < len(edits) && edits[run].op == ' ' {
+			run++
+		}
+		if run == len(edits) || run-end > 2*Context {
+			if run-end > Context {
+				return begin, end + Context
+			}
+			return begin, run
+		}
+		end = run
+	}
+	return begin, end
+}
+
+// hunkRange formats the start and length of one side of a hunk. An empty
+// range starts at the line before it, as in GNU diff.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffLines returns a shortest edit script turning a into b. It uses
+// Myers' algorithm, which takes O((N+M)D) time and O(D²) memory for D
+// changed lines, so large files with few changes stay cheap.
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	limit := n + m
+
+	// v[limit+k] is the furthest x reached on diagonal k = x-y; trace[d]
+	// holds v[limit-d : limit+d+1] after d changes
+	v := make([]int, 2*limit+2)
+	var trace [][]int
+search:
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[limit+k-1] < v[limit+k+1]) {
+				x = v[limit+k+1]
+			} else {
+				x = v[limit+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[limit+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, nil)
+				break search
+			}
+		}
+		trace = append(trace, append([]int(nil), v[limit-d:limit+d+1]...))
+	}
+
+	// Walk back from the end, collecting the edits in reverse
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{' ', a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// splitLines splits text into lines without their trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
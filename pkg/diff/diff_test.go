@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines "1" to "n", one per line
+func numbered(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{
+			name:     "identical",
+			a:        "a\nb\n",
+			b:        "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "from empty",
+			a:        "",
+			b:        "a\nb\n",
+			expected: "--- f\n+++ f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "to empty",
+			a:        "a\nb\n",
+			b:        "",
+			expected: "--- f\n+++ f\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:     "pure insertion",
+			a:        numbered(10),
+			b:        strings.Replace(numbered(10), "5\n", "5\nnew\n", 1),
+			expected: "--- f\n+++ f\n@@ -3,6 +3,7 @@\n 3\n 4\n 5\n+new\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "pure deletion",
+			a:        numbered(10),
+			b:        strings.Replace(numbered(10), "5\n", "", 1),
+			expected: "--- f\n+++ f\n@@ -2,7 +2,6 @@\n 2\n 3\n 4\n-5\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "change at the start",
+			a:        numbered(5),
+			b:        strings.Replace(numbered(5), "1\n", "one\n", 1),
+			expected: "--- f\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n",
+		},
+		{
+			name:     "change at the end",
+			a:        numbered(5),
+			b:        strings.Replace(numbered(5), "5\n", "five\n", 1),
+			expected: "--- f\n+++ f\n@@ -2,4 +2,4 @@\n 2\n 3\n 4\n-5\n+five\n",
+		},
+		{
+			name:     "hunks merge when contexts touch",
+			a:        numbered(20),
+			b:        strings.Replace(strings.Replace(numbered(20), "4\n", "four\n", 1), "11\n", "eleven\n", 1),
+			expected: "--- f\n+++ f\n@@ -1,14 +1,14 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n 8\n 9\n 10\n-11\n+eleven\n 12\n 13\n 14\n",
+		},
+		{
+			name: "hunks split when contexts do not touch",
+			a:    numbered(20),
+			b:    strings.Replace(strings.Replace(numbered(20), "4\n", "four\n", 1), "12\n", "twelve\n", 1),
+			expected: "--- f\n+++ f\n@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n" +
+				"@@ -9,7 +9,7 @@\n 9\n 10\n 11\n-12\n+twelve\n 13\n 14\n 15\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified("f", tt.a, tt.b)
+			if got != tt.expected {
+				t.Errorf("Expected diff:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int
+	}{
+		{"a b c a b b a", "c b a b a c", 5},
+		{"a b c", "a b c", 0},
+		{"a b c", "d e f", 6},
+		{"", "a", 1},
+	}
+
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		edits := diffLines(a, b)
+
+		changes := 0
+		var gotA, gotB []string
+		for _, e := range edits {
+			if e.op != ' ' {
+				changes++
+			}
+			if e.op != '+' {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != '-' {
+				gotB = append(gotB, e.line)
+			}
+		}
+		if changes != tt.changes {
+			t.Errorf("Expected %d changes from %q to %q, got %d", tt.changes, tt.a, tt.b, changes)
+		}
+		if strings.Join(gotA, " ") != tt.a || strings.Join(gotB, " ") != tt.b {
+			t.Errorf("Edit script from %q to %q does not reproduce both sides: %v", tt.a, tt.b, edits)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/takutakahashi/operation-mcp/pkg/secrets"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// readPassphrase reads the passphrase of an encrypted key from the terminal
+var readPassphrase = func(path string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("key %s is encrypted; set key_passphrase or run from a terminal", path)
+	}
+	fmt.Fprintf(os.Stderr, "Enter passphrase for key %s: ", path)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+// decryptedKeys caches the signers of encrypted keys by path, so that the
+// passphrase is asked once even when connecting to many hosts
+var decryptedKeys = struct {
+	sync.Mutex
+	signers map[string]ssh.Signer
+}{signers: make(map[string]ssh.Signer)}
+
+// publicKeySigners returns the signers for public key authentication: the
+// key file of config, paired with its certificate when there is one,
+// followed by the keys of the ssh-agent. The returned function closes the
+// connection to the agent.
+func publicKeySigners(config *SSHConfig) ([]ssh.Signer, func()) {
+	var signers []ssh.Signer
+	cleanup := func() {}
+
+	if config.KeyPath != "" {
+		signer, err := keySigner(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot use key file %s: %v\n", config.KeyPath, err)
+		} else {
+			signers = append(signers, signer)
+		}
+	}
+
+	if config.UseAgent {
+		agentSigners, conn, err := agentSigners()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot use ssh-agent: %v\n", err)
+		} else if conn != nil {
+			signers = append(signers, agentSigners...)
+			cleanup = func() { conn.Close() }
+		}
+	}
+
+	return signers, cleanup
+}
+
+// keySigner loads the key file of config. An encrypted key is decrypted with
+// key_passphrase, or with a passphrase read from the terminal once the server
+// accepts the key.
+func keySigner(config *SSHConfig) (ssh.Signer, error) {
+	buffer, err := os.ReadFile(config.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer ssh.Signer
+	key, err := ssh.ParsePrivateKey(buffer)
+	if missing, ok := err.(*ssh.PassphraseMissingError); ok {
+		signer, err = encryptedKeySigner(config, buffer, missing.PublicKey)
+	} else {
+		signer = key
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return withCertificate(signer, config)
+}
+
+// encryptedKeySigner returns a signer for an encrypted key
+func encryptedKeySigner(config *SSHConfig, buffer []byte, publicKey ssh.PublicKey) (ssh.Signer, error) {
+	decrypt := func() (ssh.Signer, error) {
+		decryptedKeys.Lock()
+		defer decryptedKeys.Unlock()
+		if signer, ok := decryptedKeys.signers[config.KeyPath]; ok {
+			return signer, nil
+		}
+
+		var passphrase []byte
+		if config.KeyPassphrase != "" {
+			resolved, err := secrets.Resolve(config.KeyPassphrase)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve key passphrase: %w", err)
+			}
+			passphrase = []byte(resolved)
+		} else {
+			var err error
+			if passphrase, err = readPassphrase(config.KeyPath); err != nil {
+				return nil, err
+			}
+		}
+
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(buffer, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		decryptedKeys.signers[config.KeyPath] = signer
+		return signer, nil
+	}
+
+	// Keys in the legacy PEM format do not expose their public key, which
+	// may be found next to them instead
+	if publicKey == nil {
+		if data, err := os.ReadFile(config.KeyPath + ".pub"); err == nil {
+			publicKey, _, _, _, _ = ssh.ParseAuthorizedKey(data)
+		}
+	}
+	if publicKey == nil {
+		return decrypt()
+	}
+	return &lazySigner{publicKey: publicKey, decrypt: decrypt}, nil
+}
+
+// withCertificate pairs a signer with the certificate of config, or with the
+// certificate found next to the key file as KEY-cert.pub
+func withCertificate(signer ssh.Signer, config *SSHConfig) (ssh.Signer, error) {
+	path := config.CertificatePath
+	if path == "" {
+		path = config.KeyPath + "-cert.pub"
+		if _, err := os.Stat(path); err != nil {
+			return signer, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", path)
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// agentSigners returns the keys of the ssh-agent at SSH_AUTH_SOCK and the
+// connection to it, which must stay open while authenticating. Without
+// SSH_AUTH_SOCK it returns no keys and a nil connection.
+func agentSigners() ([]ssh.Signer, net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return signers, conn, nil
+}
+
+// lazySigner is a signer for an encrypted key that is decrypted on first use,
+// so the passphrase is only asked when a server accepts the key
+type lazySigner struct {
+	publicKey ssh.PublicKey
+	decrypt   func() (ssh.Signer, error)
+}
+
+// PublicKey returns the public key of the encrypted key
+func (s *lazySigner) PublicKey() ssh.PublicKey {
+	return s.publicKey
+}
+
+// Sign decrypts the key and signs data
+func (s *lazySigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(rand, data)
+}
+
+// SignWithAlgorithm decrypts the key and signs data with the given algorithm
+func (s *lazySigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	algorithmSigner, ok := signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("key does not support signature algorithm %s", algorithm)
+	}
+	return algorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newTestKey generates an ed25519 key
+func newTestKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return private, signer
+}
+
+// writeTestKey writes a private key file, encrypted when passphrase is set
+func writeTestKey(t *testing.T, private ed25519.PrivateKey, passphrase string) string {
+	t.Helper()
+	var block *pem.Block
+	var err error
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(private, "")
+	}
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+// runTestCommand connects with config and runs a command
+func runTestCommand(t *testing.T, config *SSHConfig) error {
+	t.Helper()
+	exec, err := NewSSHExecutor(config, NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}))
+	if err != nil {
+		return err
+	}
+	defer exec.Close()
+	return exec.Execute([]string{"true"})
+}
+
+func TestSSHExecutorAgent(t *testing.T) {
+	server := startTestSSHServer(t)
+	private, signer := newTestKey(t)
+	server.authorize(signer.PublicKey())
+
+	// Serve an agent holding the key
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: private}); err != nil {
+		t.Fatalf("Failed to add key to agent: %v", err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	config := server.sshConfig()
+	config.Password = ""
+	config.UseAgent = true
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected agent authentication to succeed, got %v", err)
+	}
+
+	config.UseAgent = false
+	if err := runTestCommand(t, config); err == nil {
+		t.Errorf("Expected authentication to fail without the agent")
+	}
+}
+
+func TestSSHExecutorEncryptedKey(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	private, signer := newTestKey(t)
+	server.authorize(signer.PublicKey())
+	keyPath := writeTestKey(t, private, "open sesame")
+
+	prompts := 0
+	defer func(original func(string) ([]byte, error)) { readPassphrase = original }(readPassphrase)
+	readPassphrase = func(path string) ([]byte, error) {
+		prompts++
+		return []byte("open sesame"), nil
+	}
+
+	// The passphrase is read once, even for several connections
+	config := server.sshConfig()
+	config.Password = ""
+	config.KeyPath = keyPath
+	for i := 0; i < 2; i++ {
+		if err := runTestCommand(t, config); err != nil {
+			t.Fatalf("Expected key authentication to succeed, got %v", err)
+		}
+	}
+	if prompts != 1 {
+		t.Errorf("Expected 1 passphrase prompt, got %d", prompts)
+	}
+
+	// A configured passphrase is used without prompting
+	otherPrivate, otherSigner := newTestKey(t)
+	server.authorize(otherSigner.PublicKey())
+	config.KeyPath = writeTestKey(t, otherPrivate, "secret phrase")
+	config.KeyPassphrase = "secret phrase"
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected key authentication with key_passphrase to succeed, got %v", err)
+	}
+
+	// Keys the server does not accept are never decrypted
+	unknownPrivate, _ := newTestKey(t)
+	config.KeyPath = writeTestKey(t, unknownPrivate, "other")
+	config.KeyPassphrase = ""
+	config.Password = testPassword
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected password authentication to succeed, got %v", err)
+	}
+	if prompts != 1 {
+		t.Errorf("Expected no prompt for a rejected key, got %d prompts", prompts)
+	}
+}
+
+func TestSSHExecutorCertificate(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	_, authority := newTestKey(t)
+	server.trustAuthority(authority.PublicKey())
+
+	// The certificate next to the key is used, without the key itself being
+	// authorized
+	private, signer := newTestKey(t)
+	keyPath := writeTestKey(t, private, "")
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"test"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, authority); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	config := server.sshConfig()
+	config.Password = ""
+	config.KeyPath = keyPath
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected certificate authentication to succeed, got %v", err)
+	}
+
+	// An explicit certificate path must hold a certificate
+	config.CertificatePath = keyPath + ".missing"
+	if err := runTestCommand(t, config); err == nil {
+		t.Errorf("Expected authentication to fail with a missing certificate")
+	}
+}
@@ -25,14 +25,43 @@ type SSHConfig struct {
 	// KeyPath is the path to the private key file for key authentication
 	KeyPath string `yaml:"key"`
 
+	// KeyPassphrase decrypts an encrypted key. It may be a secret reference.
+	// When empty, the passphrase is read from the terminal.
+	KeyPassphrase string `yaml:"key_passphrase,omitempty"`
+
+	// CertificatePath is the path to the certificate of the key. When empty,
+	// KEY-cert.pub is used if it exists.
+	CertificatePath string `yaml:"certificate,omitempty"`
+
+	// UseAgent enables authentication with the keys of the ssh-agent at
+	// SSH_AUTH_SOCK
+	UseAgent bool `yaml:"agent"`
+
 	// VerifyHost determines whether to verify the host key
 	VerifyHost bool `yaml:"verify_host"`
 
 	// HostKeyPath is the path to the known_hosts file
 	HostKeyPath string `yaml:"host_key_path,omitempty"`
 
+	// HostKeyPolicy is how host keys are verified: strict, tofu or
+	// insecure. When empty, VerifyHost chooses strict or insecure.
+	HostKeyPolicy string `yaml:"host_key_policy,omitempty"`
+
+	// HostKeyFingerprint pins the SHA256 fingerprint of the host key, which
+	// is then verified instead of known_hosts
+	HostKeyFingerprint string `yaml:"host_key_fingerprint,omitempty"`
+
+	// ManagedHostKeyPath is the known_hosts file where the tofu policy
+	// records the keys of new hosts. Its keys are trusted like those of
+	// HostKeyPath.
+	ManagedHostKeyPath string `yaml:"-"`
+
 	// Timeout is the maximum amount of time for the connection
 	Timeout time.Duration `yaml:"timeout"`
+
+	// ProxyJump lists the jump hosts to connect through, in order. The
+	// connection to each hop is made through the previous one.
+	ProxyJump []*SSHConfig `yaml:"proxy_jump,omitempty"`
 }
 
 // NewSSHConfig creates a new SSH configuration with default values
@@ -60,18 +89,22 @@ func NewSSHConfig() *SSHConfig {
 		}
 	}
 
-	// Default known_hosts path
+	// Default known_hosts paths
 	knownHostsPath := ""
+	managedHostsPath := ""
 	if homeDir != "" {
 		knownHostsPath = filepath.Join(homeDir, ".ssh", "known_hosts")
+		managedHostsPath = filepath.Join(homeDir, ".operations", "known_hosts")
 	}
 
 	return &SSHConfig{
-		Port:        22,
-		User:        username,
-		KeyPath:     keyPath,
-		VerifyHost:  true,
-		HostKeyPath: knownHostsPath,
-		Timeout:     10 * time.Second,
+		Port:               22,
+		User:               username,
+		KeyPath:            keyPath,
+		UseAgent:           true,
+		VerifyHost:         true,
+		HostKeyPath:        knownHostsPath,
+		ManagedHostKeyPath: managedHostsPath,
+		Timeout:            10 * time.Second,
 	}
 }
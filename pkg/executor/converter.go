@@ -15,9 +15,11 @@ func SSHConfigConverter(cfg *config.SSHConfig) *SSHConfig {
 		return sshCfg
 	}
 
-	// Copy values from config.SSHConfig to executor.SSHConfig
+	// Copy values from config.SSHConfig to executor.SSHConfig. Settings of
+	// ~/.ssh/config for the host come before the explicit ones.
 	if cfg.Host != "" {
 		sshCfg.Host = cfg.Host
+		ApplyOpenSSHConfig(sshCfg)
 	}
 
 	if cfg.Port > 0 {
@@ -36,6 +38,18 @@ func SSHConfigConverter(cfg *config.SSHConfig) *SSHConfig {
 		sshCfg.KeyPath = cfg.KeyPath
 	}
 
+	if cfg.KeyPassphrase != "" {
+		sshCfg.KeyPassphrase = cfg.KeyPassphrase
+	}
+
+	if cfg.Certificate != "" {
+		sshCfg.CertificatePath = cfg.Certificate
+	}
+
+	if cfg.Agent != nil {
+		sshCfg.UseAgent = *cfg.Agent
+	}
+
 	if cfg.VerifyHost != nil {
 		sshCfg.VerifyHost = *cfg.VerifyHost
 	}
@@ -44,9 +58,27 @@ func SSHConfigConverter(cfg *config.SSHConfig) *SSHConfig {
 		sshCfg.HostKeyPath = cfg.HostKeyPath
 	}
 
+	if cfg.HostKeyPolicy != "" {
+		sshCfg.HostKeyPolicy = cfg.HostKeyPolicy
+	}
+
+	if cfg.HostKeyFingerprint != "" {
+		sshCfg.HostKeyFingerprint = cfg.HostKeyFingerprint
+	}
+
 	if cfg.Timeout > 0 {
 		sshCfg.Timeout = time.Duration(cfg.Timeout) * time.Second
 	}
 
+	// Jump hosts in the configuration replace those of ~/.ssh/config
+	if len(cfg.ProxyJump) > 0 {
+		sshCfg.ProxyJump = nil
+		for i := range cfg.ProxyJump {
+			hop := SSHConfigConverter(&cfg.ProxyJump[i])
+			hop.ProxyJump = nil
+			sshCfg.ProxyJump = append(sshCfg.ProxyJump, hop)
+		}
+	}
+
 	return sshCfg
 }
@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Environment is the context a command runs in: its working directory,
+// additional environment variables and the user it runs as
+type Environment struct {
+	// Dir is the working directory. Empty keeps the default, the current
+	// directory locally and the home directory remotely.
+	Dir string
+
+	// Env holds variables added to the environment of the command
+	Env map[string]string
+
+	// Sudo runs the command as another user when set
+	Sudo *Sudo
+
+	// TTY runs the command on a pseudo-terminal connected to the local
+	// terminal, for interactive commands. It requires stdin to be a
+	// terminal and applies to Execute only.
+	TTY bool
+}
+
+// Sudo runs commands as another user with sudo
+type Sudo struct {
+	// User is the user to run as. Empty runs as root.
+	User string
+
+	// NonInteractive makes sudo fail instead of asking for a password
+	NonInteractive bool
+}
+
+// assignments returns the variables as NAME=value, sorted by name
+func (e *Environment) assignments() []string {
+	names := make([]string, 0, len(e.Env))
+	for name := range e.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, 0, len(names))
+	for _, name := range names {
+		assignments = append(assignments, name+"="+e.Env[name])
+	}
+	return assignments
+}
+
+// sudoPrefix returns the words that run a command with sudo. sudo resets the
+// environment, so the variables are set with env after it.
+func (e *Environment) sudoPrefix() []string {
+	prefix := []string{"sudo"}
+	if e.Sudo.NonInteractive {
+		prefix = append(prefix, "-n")
+	}
+	if e.Sudo.User != "" {
+		prefix = append(prefix, "-u", e.Sudo.User)
+	}
+	prefix = append(prefix, "--")
+	if len(e.Env) > 0 {
+		prefix = append(prefix, "env")
+		prefix = append(prefix, e.assignments()...)
+	}
+	return prefix
+}
+
+// remoteCommand returns the command line run in an SSH session. Variables
+// are set on the session when the server accepts them, and with an env
+// prefix otherwise; the working directory is changed with cd.
+func (e *Environment) remoteCommand(session *ssh.Session, command []string) string {
+	line := strings.Join(command, " ")
+	if e == nil {
+		return line
+	}
+
+	var prefix []string
+	switch {
+	case e.Sudo != nil:
+		prefix = e.sudoPrefix()
+	case len(e.Env) > 0 && !e.setenv(session):
+		prefix = append([]string{"env"}, e.assignments()...)
+	}
+	if len(prefix) > 0 {
+		line = shellJoin(prefix) + " " + line
+	}
+
+	if e.Dir != "" {
+		line = "cd " + shellQuote(e.Dir) + " && " + line
+	}
+	return line
+}
+
+// setenv sets the variables on a session and reports whether the server
+// accepted all of them. Servers usually accept only the names listed in
+// AcceptEnv.
+func (e *Environment) setenv(session *ssh.Session) bool {
+	for _, assignment := range e.assignments() {
+		name, value, _ := strings.Cut(assignment, "=")
+		if err := session.Setenv(name, value); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// shellJoin quotes words for a POSIX shell and joins them with spaces
+func shellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes a word for a POSIX shell unless it needs no quoting
+func shellQuote(word string) string {
+	if word != "" && strings.Trim(word, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-=./:,@%+") == "" {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"/srv/app":   "/srv/app",
+		"FOO=bar":    "FOO=bar",
+		"":           "''",
+		"two words":  "'two words'",
+		"it's":       `'it'\''s'`,
+		"$HOME/app":  "'$HOME/app'",
+		"a;rm -rf /": "'a;rm -rf /'",
+	}
+	for word, expected := range tests {
+		if quoted := shellQuote(word); quoted != expected {
+			t.Errorf("Expected %q to be quoted as %q, got %q", word, expected, quoted)
+		}
+	}
+}
+
+func TestLocalExecutorEnvironment(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+
+	env := &Environment{Dir: dir, Env: map[string]string{"GREETING": "hello world"}}
+	exec := NewLocalExecutor(NewOptions().WithEnvironment(env))
+	output, err := exec.ExecuteWithOutput([]string{"sh", "-c", `echo "$GREETING"; pwd`})
+	if err != nil {
+		t.Fatalf("ExecuteWithOutput failed: %v", err)
+	}
+	if expected := "hello world\n" + dir + "\n"; output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+}
+
+func TestSSHExecutorEnvironment(t *testing.T) {
+	server := startTestSSHServer(t)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+	env := &Environment{Dir: dir, Env: map[string]string{"GREETING": "hello world"}}
+	command := []string{"sh", "-c", `'echo "$GREETING"; pwd'`}
+
+	run := func() string {
+		t.Helper()
+		var stdout bytes.Buffer
+		exec, err := NewSSHExecutor(server.sshConfig(), NewOptions().WithStdin(strings.NewReader("")).WithStdout(&stdout))
+		if err != nil {
+			t.Fatalf("NewSSHExecutor failed: %v", err)
+		}
+		defer exec.Close()
+		if err := exec.ExecuteIn(env, command); err != nil {
+			t.Fatalf("ExecuteIn failed: %v", err)
+		}
+		return stdout.String()
+	}
+
+	// Variables are set on the session
+	expected := "hello world\n" + dir + "\n"
+	if output := run(); output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+	executed := server.executed()
+	if last := executed[len(executed)-1]; last != "cd "+dir+` && sh -c 'echo "$GREETING"; pwd'` {
+		t.Errorf("Unexpected command with session variables: %s", last)
+	}
+
+	// Variables the server refuses are set with env
+	server.mu.Lock()
+	server.rejectEnv = true
+	server.mu.Unlock()
+	if output := run(); output != expected {
+		t.Errorf("Expected output %q, got %q", expected, output)
+	}
+	executed = server.executed()
+	if last := executed[len(executed)-1]; last != "cd "+dir+` && env 'GREETING=hello world' sh -c 'echo "$GREETING"; pwd'` {
+		t.Errorf("Unexpected command with refused variables: %s", last)
+	}
+}
+
+func TestEnvironmentSudo(t *testing.T) {
+	server := startTestSSHServer(t)
+	env := &Environment{
+		Env:  map[string]string{"RAILS_ENV": "production"},
+		Sudo: &Sudo{User: "app", NonInteractive: true},
+	}
+
+	exec, err := NewSSHExecutor(server.sshConfig(), NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}).WithStderr(&bytes.Buffer{}))
+	if err != nil {
+		t.Fatalf("NewSSHExecutor failed: %v", err)
+	}
+	defer exec.Close()
+
+	// sudo may not be usable here, so only the command line is checked
+	_ = exec.ExecuteIn(env, []string{"whoami"})
+	executed := server.executed()
+	expected := "sudo -n -u app -- env RAILS_ENV=production whoami"
+	if last := executed[len(executed)-1]; last != expected {
+		t.Errorf("Expected command %q, got %q", expected, last)
+	}
+
+	// sudo resets the environment, so the variables are not set on the
+	// session
+	server.mu.Lock()
+	_, ok := server.env["RAILS_ENV"]
+	server.mu.Unlock()
+	if ok {
+		t.Errorf("Expected RAILS_ENV not to be set on the session")
+	}
+}
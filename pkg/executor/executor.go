@@ -16,6 +16,16 @@ type Executor interface {
 	Close() error
 }
 
+// EnvironmentExecutor is an Executor that can run a command in an
+// environment other than the one of its options
+type EnvironmentExecutor interface {
+	Executor
+
+	// ExecuteIn runs a command in env and connects its stdout/stderr to the
+	// current process
+	ExecuteIn(env *Environment, command []string) error
+}
+
 // Factory creates an appropriate executor based on configuration
 type Factory interface {
 	// CreateExecutor creates an executor based on configuration
@@ -32,6 +42,10 @@ type Options struct {
 
 	// Stderr is the error stream for the executed command
 	Stderr io.Writer
+
+	// Environment sets the working directory, variables and user of the
+	// executed command
+	Environment *Environment
 }
 
 // NewOptions creates a default Options struct
@@ -56,3 +70,9 @@ func (o *Options) WithStderr(stderr io.Writer) *Options {
 	o.Stderr = stderr
 	return o
 }
+
+// WithEnvironment sets the environment option
+func (o *Options) WithEnvironment(env *Environment) *Options {
+	o.Environment = env
+	return o
+}
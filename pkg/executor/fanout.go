@@ -0,0 +1,246 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of targets a fan-out runs on at once
+// when no limit is set
+const DefaultConcurrency = 10
+
+// FanOutOptions controls how a command runs across several targets
+type FanOutOptions struct {
+	// Concurrency is the maximum number of targets running at once
+	Concurrency int
+
+	// FailFast stops starting new targets after the first failure
+	FailFast bool
+
+	// MaxFailures stops starting new targets once this many have failed.
+	// Zero means no limit.
+	MaxFailures int
+
+	// Timeout is the maximum time a command may run on one target. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	// Stdout and Stderr receive the output of every target, each line
+	// prefixed with the target name
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Environment sets the working directory, variables and user of the
+	// command on every target
+	Environment *Environment
+}
+
+// ExecutionResult is the outcome of running a command on one target
+type ExecutionResult struct {
+	Target   string
+	Err      error
+	TimedOut bool
+	// Skipped is true when the command was not started because the fan-out
+	// was stopped by earlier failures
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Succeeded reports whether the command ran and succeeded
+func (r ExecutionResult) Succeeded() bool {
+	return !r.Skipped && !r.TimedOut && r.Err == nil
+}
+
+// Summary counts the results of a fan-out
+type Summary struct {
+	Succeeded int
+	Failed    int
+	TimedOut  int
+	Skipped   int
+}
+
+// Summarize counts the results of a fan-out
+func Summarize(results []ExecutionResult) Summary {
+	var summary Summary
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			summary.Skipped++
+		case result.TimedOut:
+			summary.TimedOut++
+		case result.Err != nil:
+			summary.Failed++
+		default:
+			summary.Succeeded++
+		}
+	}
+	return summary
+}
+
+// String formats a summary for display
+func (s Summary) String() string {
+	return fmt.Sprintf("%d succeeded, %d failed, %d timed out, %d skipped", s.Succeeded, s.Failed, s.TimedOut, s.Skipped)
+}
+
+// FanOut runs a command on each target with one executor per target. The
+// results are returned in the order of targets.
+func (r *Registry) FanOut(targets []string, command []string, options FanOutOptions) []ExecutionResult {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	maxFailures := options.MaxFailures
+	if options.FailFast {
+		maxFailures = 1
+	}
+	stdout, stderr := options.Stdout, options.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	// Output of all targets goes through one lock, so lines never interleave
+	var outputMu sync.Mutex
+
+	var mu sync.Mutex
+	failures := 0
+
+	results := make([]ExecutionResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stopped := maxFailures > 0 && failures >= maxFailures
+		mu.Unlock()
+		if stopped {
+			<-sem
+			results[i] = ExecutionResult{Target: target, Skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			prefix := "[" + target + "] "
+			out := newPrefixWriter(stdout, prefix, &outputMu)
+			errOut := newPrefixWriter(stderr, prefix, &outputMu)
+			opts := NewOptions().WithStdin(strings.NewReader("")).WithStdout(out).WithStderr(errOut).WithEnvironment(options.Environment)
+
+			result := r.runOne(target, command, opts, options.Timeout)
+			out.Flush()
+			errOut.Flush()
+
+			if !result.Succeeded() {
+				mu.Lock()
+				failures++
+				mu.Unlock()
+			}
+			results[i] = result
+		}(i, target)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// runOne runs a command on a single target, giving up after timeout
+func (r *Registry) runOne(target string, command []string, options *Options, timeout time.Duration) ExecutionResult {
+	start := time.Now()
+	result := ExecutionResult{Target: target}
+
+	factory, err := r.FactoryWithOptions(target, options)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	exec, err := factory.CreateExecutor()
+	if err != nil {
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer exec.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Execute(command)
+	}()
+
+	if timeout <= 0 {
+		result.Err = <-done
+	} else {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case result.Err = <-done:
+		case <-timer.C:
+			// Closing the executor ends the remote session or kills the
+			// local process
+			exec.Close()
+			result.TimedOut = true
+			result.Err = fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// prefixWriter writes complete lines to an underlying writer, each prefixed
+// with a fixed string. Partial lines are kept until completed or flushed.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+// newPrefixWriter creates a prefixWriter that locks mu around each write
+func newPrefixWriter(out io.Writer, prefix string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{out: out, prefix: prefix, mu: mu}
+}
+
+// Write buffers p and writes every complete line
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+		if err := w.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes a remaining partial line
+func (w *prefixWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := append(w.buf.Bytes(), '\n')
+	w.buf.Reset()
+	return w.writeLine(line)
+}
+
+// writeLine writes one prefixed line
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
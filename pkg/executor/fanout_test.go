@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+// testTarget returns a target for the test SSH server
+func testTarget(server *testSSHServer, labels map[string]string) config.Target {
+	verify := false
+	return config.Target{
+		SSHConfig: config.SSHConfig{
+			Host:       server.host(),
+			Port:       server.port(),
+			User:       "test",
+			Password:   testPassword,
+			VerifyHost: &verify,
+			Timeout:    5,
+		},
+		Labels: labels,
+	}
+}
+
+// unreachableTarget returns a target nothing listens on
+func unreachableTarget(labels map[string]string) config.Target {
+	verify := false
+	return config.Target{
+		SSHConfig: config.SSHConfig{Host: "127.0.0.1", Port: 1, Password: testPassword, VerifyHost: &verify, Timeout: 5},
+		Labels:    labels,
+	}
+}
+
+func TestRegistrySelect(t *testing.T) {
+	registry := NewRegistry(map[string]config.Target{
+		"app1": {SSHConfig: config.SSHConfig{Host: "app1"}, Labels: map[string]string{"role": "app", "env": "prod"}},
+		"app2": {SSHConfig: config.SSHConfig{Host: "app2"}, Labels: map[string]string{"role": "app", "env": "staging"}},
+		"db1":  {SSHConfig: config.SSHConfig{Host: "db1"}, Labels: map[string]string{"role": "db", "env": "prod"}},
+	}, nil)
+
+	tests := []struct {
+		selector string
+		expected []string
+		wantErr  bool
+	}{
+		{selector: "db1,app1", expected: []string{"db1", "app1"}},
+		{selector: "app1, app1", expected: []string{"app1"}},
+		{selector: "role=app", expected: []string{"app1", "app2"}},
+		{selector: "role=app,env!=prod", expected: []string{"app2"}},
+		{selector: "env=prod", expected: []string{"app1", "db1"}},
+		{selector: "unknown", wantErr: true},
+		{selector: "role=cache", wantErr: true},
+		{selector: "role=app,db1", wantErr: true},
+		{selector: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		names, err := registry.Select(test.selector)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for selector %q, got %v", test.selector, names)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Select failed for %q: %v", test.selector, err)
+			continue
+		}
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("Expected %v for selector %q, got %v", test.expected, test.selector, names)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFanOut(t *testing.T) {
+	server := startTestSSHServer(t)
+	registry := NewRegistry(map[string]config.Target{
+		"app1": testTarget(server, nil),
+		"app2": testTarget(server, nil),
+		"down": unreachableTarget(nil),
+	}, nil)
+
+	var stdout, stderr syncBuffer
+	results := registry.FanOut([]string{"app1", "down", "app2"}, []string{"printf", "'a\\nb'"}, FanOutOptions{
+		Concurrency: 2,
+		Stdout:      &stdout,
+		Stderr:      &stderr,
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if !results[0].Succeeded() || !results[2].Succeeded() {
+		t.Errorf("Expected app1 and app2 to succeed, got %+v", results)
+	}
+	if results[1].Succeeded() || results[1].Target != "down" {
+		t.Errorf("Expected down to fail, got %+v", results[1])
+	}
+
+	summary := Summarize(results)
+	if summary != (Summary{Succeeded: 2, Failed: 1}) {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+
+	// Every line is prefixed with its target, including the last partial line
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{"[app1] a", "[app1] b", "[app2] a", "[app2] b"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected output %v, got %v", expected, lines)
+	}
+}
+
+func TestFanOutFailures(t *testing.T) {
+	server := startTestSSHServer(t)
+	registry := NewRegistry(map[string]config.Target{
+		"down1": unreachableTarget(nil),
+		"down2": unreachableTarget(nil),
+		"app1":  testTarget(server, nil),
+		"app2":  testTarget(server, nil),
+	}, nil)
+	targets := []string{"down1", "down2", "app1", "app2"}
+
+	// With fail_fast and one target at a time, nothing runs after the first failure
+	results := registry.FanOut(targets, []string{"true"}, FanOutOptions{Concurrency: 1, FailFast: true, Stdout: &syncBuffer{}})
+	if summary := Summarize(results); summary != (Summary{Failed: 1, Skipped: 3}) {
+		t.Errorf("Unexpected summary with fail_fast: %s", summary)
+	}
+
+	// max_failures allows the given number of failures
+	results = registry.FanOut(targets, []string{"true"}, FanOutOptions{Concurrency: 1, MaxFailures: 2, Stdout: &syncBuffer{}})
+	if summary := Summarize(results); summary != (Summary{Failed: 2, Skipped: 2}) {
+		t.Errorf("Unexpected summary with max_failures: %s", summary)
+	}
+
+	// A command exceeding the timeout is reported as timed out
+	start := time.Now()
+	results = registry.FanOut([]string{"app1"}, []string{"sleep", "5"}, FanOutOptions{Timeout: 200 * time.Millisecond, Stdout: &syncBuffer{}})
+	if !results[0].TimedOut {
+		t.Errorf("Expected timeout, got %+v", results[0])
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Expected fan-out to stop after the timeout, took %s", time.Since(start))
+	}
+
+	// A local command is killed on timeout rather than left running
+	marker := filepath.Join(t.TempDir(), "finished")
+	results = registry.FanOut([]string{config.LocalTarget}, []string{"sh", "-c", "sleep 1; touch " + marker}, FanOutOptions{Timeout: 200 * time.Millisecond, Stdout: &syncBuffer{}})
+	if !results[0].TimedOut {
+		t.Errorf("Expected timeout, got %+v", results[0])
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Expected the local command to be killed, got %v", err)
+	}
+}
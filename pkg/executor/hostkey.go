@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key policies
+const (
+	// HostKeyPolicyStrict accepts only host keys found in known_hosts
+	HostKeyPolicyStrict = config.HostKeyPolicyStrict
+	// HostKeyPolicyTOFU accepts and records the key of a host seen for the
+	// first time, and rejects keys that differ from a recorded one
+	HostKeyPolicyTOFU = config.HostKeyPolicyTOFU
+	// HostKeyPolicyInsecure accepts any host key
+	HostKeyPolicyInsecure = config.HostKeyPolicyInsecure
+)
+
+// managedHostKeys serializes writes to the managed known_hosts file
+var managedHostKeys sync.Mutex
+
+// hostKeyPolicy returns the host key policy of config. Without an explicit
+// policy, VerifyHost chooses between strict and insecure.
+func hostKeyPolicy(config *SSHConfig) string {
+	if config.HostKeyPolicy != "" {
+		return config.HostKeyPolicy
+	}
+	if config.VerifyHost {
+		return HostKeyPolicyStrict
+	}
+	return HostKeyPolicyInsecure
+}
+
+// hostKeyCallback returns the callback that verifies the host key of the
+// server at addr, and the host key algorithms to prefer for it. A pinned
+// fingerprint takes precedence over known_hosts files.
+func hostKeyCallback(config *SSHConfig, addr string) (ssh.HostKeyCallback, []string, error) {
+	if config.HostKeyFingerprint != "" {
+		return pinnedHostKey(config.HostKeyFingerprint), nil, nil
+	}
+
+	policy := hostKeyPolicy(config)
+	switch policy {
+	case HostKeyPolicyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case HostKeyPolicyStrict, HostKeyPolicyTOFU:
+	default:
+		return nil, nil, fmt.Errorf("unknown host key policy: %s", policy)
+	}
+
+	// Both the known_hosts file and the managed file hold trusted keys
+	var files []string
+	for _, path := range []string{config.HostKeyPath, config.ManagedHostKeyPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("cannot use known_hosts file %s: %w", path, err)
+		}
+		files = append(files, path)
+	}
+
+	known := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return &knownhosts.KeyError{}
+	}
+	if len(files) > 0 {
+		var err error
+		if known, err = knownhosts.New(files...); err != nil {
+			return nil, nil, fmt.Errorf("cannot use known_hosts files %s: %w", strings.Join(files, ", "), err)
+		}
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key of %s does not match the known key (%s at %s:%d); the host may be impersonated, or its key was changed and must be updated in known_hosts",
+				hostname, ssh.FingerprintSHA256(keyErr.Want[0].Key), keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+		if policy == HostKeyPolicyStrict {
+			return fmt.Errorf("host key of %s (%s) is unknown; add it to known_hosts, pin it with host_key_fingerprint, or use host_key_policy: tofu",
+				hostname, ssh.FingerprintSHA256(key))
+		}
+		return trustHostKey(config.ManagedHostKeyPath, hostname, key)
+	}
+
+	return callback, knownHostKeyAlgorithms(known, addr), nil
+}
+
+// pinnedHostKey returns a callback accepting only the host key with the
+// given SHA256 fingerprint
+func pinnedHostKey(fingerprint string) ssh.HostKeyCallback {
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		fingerprint = "SHA256:" + fingerprint
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+			return fmt.Errorf("host key of %s has fingerprint %s, expected %s", hostname, actual, fingerprint)
+		}
+		return nil
+	}
+}
+
+// trustHostKey records the key of a host seen for the first time in the
+// managed known_hosts file
+func trustHostKey(path, hostname string, key ssh.PublicKey) error {
+	if path == "" {
+		return fmt.Errorf("host key of %s is unknown and no file is set to record it", hostname)
+	}
+
+	managedHostKeys.Lock()
+	defer managedHostKeys.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to record host key of %s: %w", hostname, err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to record host key of %s: %w", hostname, err)
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		return fmt.Errorf("failed to record host key of %s: %w", hostname, err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: trusting host key of %s (%s) on first use; recorded in %s\n", hostname, ssh.FingerprintSHA256(key), path)
+	return nil
+}
+
+// knownHostKeyAlgorithms returns the algorithms of the keys known for addr,
+// so that the server presents a key that can be verified instead of another
+// type, which would be reported as a mismatch
+func knownHostKeyAlgorithms(known ssh.HostKeyCallback, addr string) []string {
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if err := known(addr, &net.TCPAddr{IP: net.IPv4zero}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		switch want.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, want.Key.Type())
+		}
+	}
+	return algorithms
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSSHExecutorHostKeyPolicy(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	dir := t.TempDir()
+	addr := fmt.Sprintf("%s:%d", server.host(), server.port())
+
+	config := server.sshConfig()
+	config.HostKeyPath = filepath.Join(dir, "known_hosts")
+	config.ManagedHostKeyPath = filepath.Join(dir, "managed", "known_hosts")
+
+	// Unknown hosts are rejected by the strict policy
+	config.HostKeyPolicy = HostKeyPolicyStrict
+	if err := runTestCommand(t, config); err == nil || !strings.Contains(err.Error(), "is unknown") {
+		t.Errorf("Expected an unknown host key to be rejected, got %v", err)
+	}
+
+	// verify_host without a policy is strict
+	config.HostKeyPolicy = ""
+	config.VerifyHost = true
+	if err := runTestCommand(t, config); err == nil {
+		t.Errorf("Expected verify_host to reject an unknown host key")
+	}
+
+	// The tofu policy records the key of a new host, then accepts it
+	config.HostKeyPolicy = HostKeyPolicyTOFU
+	for i := 0; i < 2; i++ {
+		if err := runTestCommand(t, config); err != nil {
+			t.Fatalf("Expected tofu connection %d to succeed, got %v", i+1, err)
+		}
+	}
+	data, err := os.ReadFile(config.ManagedHostKeyPath)
+	if err != nil {
+		t.Fatalf("Failed to read managed known_hosts: %v", err)
+	}
+	expected := knownhosts.Line([]string{knownhosts.Normalize(addr)}, server.hostKey.PublicKey()) + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected managed known_hosts %q, got %q", expected, string(data))
+	}
+
+	// Recorded keys are trusted by the strict policy as well
+	config.HostKeyPolicy = HostKeyPolicyStrict
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected a recorded host key to be accepted, got %v", err)
+	}
+
+	// A changed key is rejected by every verifying policy
+	_, other := newTestKey(t)
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, other.PublicKey())
+	if err := os.WriteFile(config.ManagedHostKeyPath, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write managed known_hosts: %v", err)
+	}
+	for _, policy := range []string{HostKeyPolicyStrict, HostKeyPolicyTOFU} {
+		config.HostKeyPolicy = policy
+		if err := runTestCommand(t, config); err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("Expected a changed host key to be rejected with policy %s, got %v", policy, err)
+		}
+	}
+
+	config.HostKeyPolicy = HostKeyPolicyInsecure
+	if err := runTestCommand(t, config); err != nil {
+		t.Errorf("Expected the insecure policy to accept any host key, got %v", err)
+	}
+}
+
+func TestSSHExecutorHostKeyFingerprint(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestSSHServer(t)
+	fingerprint := ssh.FingerprintSHA256(server.hostKey.PublicKey())
+
+	config := server.sshConfig()
+	config.HostKeyPolicy = HostKeyPolicyStrict
+	for _, pinned := range []string{fingerprint, strings.TrimPrefix(fingerprint, "SHA256:")} {
+		config.HostKeyFingerprint = pinned
+		if err := runTestCommand(t, config); err != nil {
+			t.Errorf("Expected the pinned host key %s to be accepted, got %v", pinned, err)
+		}
+	}
+
+	_, other := newTestKey(t)
+	config.HostKeyFingerprint = ssh.FingerprintSHA256(other.PublicKey())
+	if err := runTestCommand(t, config); err == nil || !strings.Contains(err.Error(), "expected "+config.HostKeyFingerprint) {
+		t.Errorf("Expected a host key with another fingerprint to be rejected, got %v", err)
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaPublic, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to create public key: %v", err)
+	}
+	_, ed25519Signer := newTestKey(t)
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	lines := knownhosts.Line([]string{"example.com"}, rsaPublic) + "\n" +
+		knownhosts.Line([]string{"[example.com]:2222"}, ed25519Signer.PublicKey()) + "\n"
+	if err := os.WriteFile(path, []byte(lines), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+	known, err := knownhosts.New(path)
+	if err != nil {
+		t.Fatalf("Failed to read known_hosts: %v", err)
+	}
+
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "example.com:22", expected: "rsa-sha2-512,rsa-sha2-256,ssh-rsa"},
+		{addr: "example.com:2222", expected: "ssh-ed25519"},
+		{addr: "unknown.example.com:22", expected: ""},
+	}
+	for _, test := range tests {
+		if got := strings.Join(knownHostKeyAlgorithms(known, test.addr), ","); got != test.expected {
+			t.Errorf("Expected algorithms %q for %s, got %q", test.expected, test.addr, got)
+		}
+	}
+}
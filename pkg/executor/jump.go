@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dial connects to the SSH server of config, through each of its jump hosts
+// in order. It returns the client for the server and the clients for the jump
+// hosts, which must be closed after it.
+func dial(config *SSHConfig) (*ssh.Client, []*ssh.Client, error) {
+	var jumps []*ssh.Client
+	closeJumps := func() {
+		for i := len(jumps) - 1; i >= 0; i-- {
+			jumps[i].Close()
+		}
+	}
+
+	hops := append(append([]*SSHConfig(nil), config.ProxyJump...), config)
+	for i, hop := range hops {
+		kind := "ssh server"
+		if i < len(hops)-1 {
+			kind = "jump host"
+		}
+		addr := net.JoinHostPort(hop.Host, strconv.Itoa(hop.Port))
+
+		clientConfig, cleanup, err := createSSHConfig(hop)
+		if err != nil {
+			closeJumps()
+			return nil, nil, fmt.Errorf("failed to create ssh client config for %s %s: %w", kind, addr, err)
+		}
+
+		var client *ssh.Client
+		if len(jumps) == 0 {
+			client, err = ssh.Dial("tcp", addr, clientConfig)
+		} else {
+			client, err = dialThrough(jumps[len(jumps)-1], addr, clientConfig)
+		}
+		cleanup()
+		if err != nil {
+			closeJumps()
+			if len(jumps) > 0 {
+				return nil, nil, fmt.Errorf("failed to connect to %s %s via %s: %w", kind, addr, jumps[len(jumps)-1].RemoteAddr(), err)
+			}
+			return nil, nil, fmt.Errorf("failed to connect to %s %s: %w", kind, addr, err)
+		}
+
+		if i == len(hops)-1 {
+			return client, jumps, nil
+		}
+		jumps = append(jumps, client)
+	}
+
+	// Not reached: hops always holds config itself
+	return nil, nil, fmt.Errorf("no ssh server to connect to")
+}
+
+// dialThrough opens an SSH connection to addr through a connected jump host.
+// The handshake is abandoned after the config's timeout, since connections
+// through a jump host do not support deadlines.
+func dialThrough(jump *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := jump.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	type handshake struct {
+		conn     ssh.Conn
+		channels <-chan ssh.NewChannel
+		requests <-chan *ssh.Request
+		err      error
+	}
+	done := make(chan handshake, 1)
+	go func() {
+		var h handshake
+		h.conn, h.channels, h.requests, h.err = ssh.NewClientConn(conn, addr, config)
+		done <- h
+	}()
+
+	var timeout <-chan time.Time
+	if config.Timeout > 0 {
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case h := <-done:
+		if h.err != nil {
+			conn.Close()
+			return nil, h.err
+		}
+		return ssh.NewClient(h.conn, h.channels, h.requests), nil
+	case <-timeout:
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake timed out after %s", config.Timeout)
+	}
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSSHExecutorProxyJump(t *testing.T) {
+	bastion := startTestSSHServer(t)
+	server := startTestSSHServer(t)
+
+	config := server.sshConfig()
+	config.ProxyJump = []*SSHConfig{bastion.sshConfig()}
+
+	var stdout bytes.Buffer
+	exec, err := NewSSHExecutor(config, NewOptions().WithStdin(strings.NewReader("")).WithStdout(&stdout))
+	if err != nil {
+		t.Fatalf("NewSSHExecutor failed: %v", err)
+	}
+	if err := exec.Execute([]string{"echo", "hello"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := exec.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	if stdout.String() != "hello\n" {
+		t.Errorf("Expected output %q, got %q", "hello\n", stdout.String())
+	}
+	if commands := server.executed(); len(commands) != 1 || commands[0] != "echo hello" {
+		t.Errorf("Expected the command to run on the server, got %v", commands)
+	}
+	if commands := bastion.executed(); len(commands) != 0 {
+		t.Errorf("Expected no command to run on the jump host, got %v", commands)
+	}
+	addr := fmt.Sprintf("%s:%d", server.host(), server.port())
+	if dials := bastion.dialed(); len(dials) != 1 || dials[0] != addr {
+		t.Errorf("Expected the jump host to forward to %s, got %v", addr, dials)
+	}
+}
+
+func TestSSHExecutorProxyJumpChain(t *testing.T) {
+	first := startTestSSHServer(t)
+	second := startTestSSHServer(t)
+	server := startTestSSHServer(t)
+
+	config := server.sshConfig()
+	config.ProxyJump = []*SSHConfig{first.sshConfig(), second.sshConfig()}
+
+	exec, err := NewSSHExecutor(config, NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}))
+	if err != nil {
+		t.Fatalf("NewSSHExecutor failed: %v", err)
+	}
+	defer exec.Close()
+	if err := exec.Execute([]string{"true"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// Each hop is reached through the previous one
+	if dials := first.dialed(); len(dials) != 1 || !strings.HasSuffix(dials[0], fmt.Sprintf(":%d", second.port())) {
+		t.Errorf("Expected the first hop to forward to the second, got %v", dials)
+	}
+	if dials := second.dialed(); len(dials) != 1 || !strings.HasSuffix(dials[0], fmt.Sprintf(":%d", server.port())) {
+		t.Errorf("Expected the second hop to forward to the server, got %v", dials)
+	}
+}
+
+func TestSSHExecutorProxyJumpErrors(t *testing.T) {
+	bastion := startTestSSHServer(t)
+	server := startTestSSHServer(t)
+
+	// Each hop authenticates on its own
+	jump := bastion.sshConfig()
+	jump.Password = "wrong"
+	config := server.sshConfig()
+	config.ProxyJump = []*SSHConfig{jump}
+	_, err := NewSSHExecutor(config, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to jump host") {
+		t.Errorf("Expected jump host authentication to fail, got %v", err)
+	}
+
+	// The server must be reachable from the jump host
+	config = server.sshConfig()
+	config.Port = 1
+	config.ProxyJump = []*SSHConfig{bastion.sshConfig()}
+	_, err = NewSSHExecutor(config, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to ssh server 127.0.0.1:1 via") {
+		t.Errorf("Expected connecting through the jump host to fail, got %v", err)
+	}
+}
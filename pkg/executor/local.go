@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sync"
 )
 
 // LocalExecutor implements the Executor interface for local command execution
 type LocalExecutor struct {
 	options *Options
+
+	// running holds the commands in progress, which Close kills
+	mu      sync.Mutex
+	running map[*exec.Cmd]bool
 }
 
 // NewLocalExecutor creates a new LocalExecutor with the given options
@@ -32,21 +37,35 @@ func NewLocalExecutor(options *Options) *LocalExecutor {
 
 	return &LocalExecutor{
 		options: options,
+		running: make(map[*exec.Cmd]bool),
 	}
 }
 
 // Execute runs a command locally and connects its stdout/stderr to the current process
 func (e *LocalExecutor) Execute(command []string) error {
+	return e.ExecuteIn(e.options.Environment, command)
+}
+
+// ExecuteIn runs a command locally in env and connects its stdout/stderr to
+// the current process
+func (e *LocalExecutor) ExecuteIn(env *Environment, command []string) error {
 	if len(command) == 0 {
 		return fmt.Errorf("empty command")
 	}
 
-	cmd := exec.Command(command[0], command[1:]...)
+	// The command inherits the local terminal, which must be one
+	if env != nil && env.TTY {
+		if _, err := terminalFd(e.options.Stdin); err != nil {
+			return err
+		}
+	}
+
+	cmd := newCommand(env, command)
 	cmd.Stdin = e.options.Stdin
 	cmd.Stdout = e.options.Stdout
 	cmd.Stderr = e.options.Stderr
 
-	return cmd.Run()
+	return e.run(cmd)
 }
 
 // ExecuteWithOutput runs a command locally and returns its combined output
@@ -56,12 +75,12 @@ func (e *LocalExecutor) ExecuteWithOutput(command []string) (string, error) {
 	}
 
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command(command[0], command[1:]...)
+	cmd := newCommand(e.options.Environment, command)
 	cmd.Stdin = e.options.Stdin
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err := e.run(cmd)
 	if err != nil {
 		return stderr.String(), err
 	}
@@ -69,8 +88,49 @@ func (e *LocalExecutor) ExecuteWithOutput(command []string) (string, error) {
 	return stdout.String(), nil
 }
 
-// Close does nothing for LocalExecutor as there are no resources to release
+// newCommand creates a command running in env
+func newCommand(env *Environment, command []string) *exec.Cmd {
+	if env == nil {
+		return exec.Command(command[0], command[1:]...)
+	}
+
+	if env.Sudo != nil {
+		command = append(env.sudoPrefix(), command...)
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = env.Dir
+	if len(env.Env) > 0 {
+		cmd.Env = append(os.Environ(), env.assignments()...)
+	}
+	return cmd
+}
+
+// run runs a command, tracking it until it exits so that Close can kill it
+func (e *LocalExecutor) run(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.running[cmd] = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.running, cmd)
+		e.mu.Unlock()
+	}()
+
+	return cmd.Wait()
+}
+
+// Close kills the commands still running, such as one that timed out
 func (e *LocalExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for cmd := range e.running {
+		cmd.Process.Kill()
+	}
 	return nil
 }
 
@@ -0,0 +1,243 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// openSSHHost holds the settings of an OpenSSH client configuration file
+// that apply to one host
+type openSSHHost struct {
+	HostName      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	ProxyJump     string
+}
+
+// openSSHConfig is a parsed OpenSSH client configuration file. Only the Host
+// keyword and the settings of openSSHHost are supported; Match blocks are
+// ignored.
+type openSSHConfig struct {
+	blocks []openSSHBlock
+}
+
+// openSSHBlock is the settings following a Host line
+type openSSHBlock struct {
+	patterns []string
+	settings [][2]string // lowercase keyword and value
+}
+
+// ApplyOpenSSHConfig applies the settings of ~/.ssh/config for the host of
+// config. A Host alias is replaced by its HostName, and User, Port,
+// IdentityFile and ProxyJump replace the values of config, so explicit
+// settings must be applied afterwards.
+func ApplyOpenSSHConfig(config *SSHConfig) {
+	home, err := os.UserHomeDir()
+	if err != nil || config.Host == "" {
+		return
+	}
+	configPath := filepath.Join(home, ".ssh", "config")
+
+	file, err := os.Open(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: cannot read %s: %v\n", configPath, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	sshConfig, err := parseOpenSSHConfig(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot parse %s: %v\n", configPath, err)
+		return
+	}
+	sshConfig.apply(config, home, true)
+}
+
+// apply applies the settings for the host of config, including its jump
+// hosts when withJumps is set
+func (c *openSSHConfig) apply(config *SSHConfig, home string, withJumps bool) {
+	host := c.lookup(config.Host)
+	if host.HostName != "" {
+		config.Host = host.HostName
+	}
+	if host.User != "" {
+		config.User = host.User
+	}
+	if host.Port > 0 {
+		config.Port = host.Port
+	}
+	for _, identityFile := range host.IdentityFiles {
+		identityFile = expandHome(identityFile, home)
+		if _, err := os.Stat(identityFile); err == nil {
+			config.KeyPath = identityFile
+			break
+		}
+	}
+
+	if !withJumps || host.ProxyJump == "" || strings.EqualFold(host.ProxyJump, "none") {
+		return
+	}
+	config.ProxyJump = nil
+	for _, spec := range strings.Split(host.ProxyJump, ",") {
+		user, hostPort := "", strings.TrimSpace(spec)
+		if at := strings.LastIndex(hostPort, "@"); at >= 0 {
+			user, hostPort = hostPort[:at], hostPort[at+1:]
+		}
+		hopHost, port := hostPort, 0
+		if i := strings.LastIndex(hostPort, ":"); i >= 0 {
+			if p, err := strconv.Atoi(hostPort[i+1:]); err == nil {
+				hopHost, port = hostPort[:i], p
+			}
+		}
+
+		// Each hop is resolved in turn, except for its own jump hosts
+		hop := NewSSHConfig()
+		hop.Host = hopHost
+		c.apply(hop, home, false)
+		if user != "" {
+			hop.User = user
+		}
+		if port > 0 {
+			hop.Port = port
+		}
+		config.ProxyJump = append(config.ProxyJump, hop)
+	}
+}
+
+// lookup returns the settings for a host. As in OpenSSH, the first value
+// obtained for each setting wins, except for IdentityFile, whose values
+// accumulate.
+func (c *openSSHConfig) lookup(alias string) openSSHHost {
+	var host openSSHHost
+	for _, block := range c.blocks {
+		if !matchHostPatterns(block.patterns, alias) {
+			continue
+		}
+		for _, setting := range block.settings {
+			keyword, value := setting[0], setting[1]
+			switch keyword {
+			case "hostname":
+				if host.HostName == "" {
+					host.HostName = strings.ReplaceAll(value, "%h", alias)
+				}
+			case "user":
+				if host.User == "" {
+					host.User = value
+				}
+			case "port":
+				if host.Port == 0 {
+					host.Port, _ = strconv.Atoi(value)
+				}
+			case "identityfile":
+				host.IdentityFiles = append(host.IdentityFiles, value)
+			case "proxyjump":
+				if host.ProxyJump == "" {
+					host.ProxyJump = value
+				}
+			}
+		}
+	}
+	return host
+}
+
+// matchHostPatterns reports whether a host matches the patterns of a Host
+// line. A negated pattern (!pattern) that matches excludes the host.
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		ok, err := path.Match(pattern, host)
+		if err != nil || !ok {
+			continue
+		}
+		if negate {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// parseOpenSSHConfig parses an OpenSSH client configuration file. Settings
+// before the first Host line apply to every host.
+func parseOpenSSHConfig(r io.Reader) (*openSSHConfig, error) {
+	config := &openSSHConfig{}
+	current := &openSSHBlock{patterns: []string{"*"}}
+	ignoring := false
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, value := splitOpenSSHLine(line)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: missing value for %s", lineNumber, keyword)
+		}
+
+		switch strings.ToLower(keyword) {
+		case "host":
+			config.blocks = append(config.blocks, *current)
+			current = &openSSHBlock{patterns: strings.Fields(value)}
+			ignoring = false
+		case "match":
+			// Match criteria are not supported, so their settings never apply
+			config.blocks = append(config.blocks, *current)
+			current = &openSSHBlock{}
+			ignoring = true
+		default:
+			if !ignoring {
+				current.settings = append(current.settings, [2]string{strings.ToLower(keyword), unquote(value)})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	config.blocks = append(config.blocks, *current)
+	return config, nil
+}
+
+// splitOpenSSHLine splits a line into its keyword and value, which are
+// separated by whitespace or an equals sign
+func splitOpenSSHLine(line string) (string, string) {
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return line, ""
+	}
+	keyword, rest := line[:end], strings.TrimSpace(line[end:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+	return keyword, rest
+}
+
+// unquote removes the double quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func expandHome(name, home string) string {
+	if name == "~" {
+		return home
+	}
+	if strings.HasPrefix(name, "~/") {
+		return filepath.Join(home, name[2:])
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+func TestParseOpenSSHConfig(t *testing.T) {
+	sshConfig, err := parseOpenSSHConfig(strings.NewReader(`
+# Global settings come first
+IdentityFile ~/.ssh/global
+
+Host prod-* !prod-legacy
+  HostName %h.example.com
+  User deploy
+  Port=2222
+  IdentityFile "~/.ssh/prod"
+
+Match user root
+  User root
+
+Host prod-web
+  User ignored
+  ProxyJump jump@bastion:2200,inner
+
+Host *
+  User fallback
+`))
+	if err != nil {
+		t.Fatalf("parseOpenSSHConfig failed: %v", err)
+	}
+
+	host := sshConfig.lookup("prod-web")
+	if host.HostName != "prod-web.example.com" || host.User != "deploy" || host.Port != 2222 {
+		t.Errorf("Unexpected settings for prod-web: %+v", host)
+	}
+	if strings.Join(host.IdentityFiles, ",") != "~/.ssh/global,~/.ssh/prod" {
+		t.Errorf("Expected identity files to accumulate, got %v", host.IdentityFiles)
+	}
+	if host.ProxyJump != "jump@bastion:2200,inner" {
+		t.Errorf("Unexpected ProxyJump: %q", host.ProxyJump)
+	}
+
+	// Negated patterns exclude a host
+	host = sshConfig.lookup("prod-legacy")
+	if host.HostName != "" || host.User != "fallback" {
+		t.Errorf("Unexpected settings for prod-legacy: %+v", host)
+	}
+
+	if _, err := parseOpenSSHConfig(strings.NewReader("Host\n")); err == nil {
+		t.Errorf("Expected error for a missing value")
+	}
+}
+
+func TestSSHConfigConverterOpenSSHConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+	for _, name := range []string{"prod", "bastion"} {
+		if err := os.WriteFile(filepath.Join(sshDir, name), []byte("key"), 0600); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "config"), []byte(`
+Host web
+  HostName web.internal
+  User deploy
+  Port 2222
+  IdentityFile ~/.ssh/missing
+  IdentityFile ~/.ssh/prod
+  ProxyJump ops@bastion:2200
+
+Host bastion
+  HostName bastion.example.com
+  IdentityFile ~/.ssh/bastion
+  ProxyJump loop
+`), 0600); err != nil {
+		t.Fatalf("Failed to write ssh config: %v", err)
+	}
+
+	// Settings of ~/.ssh/config apply to the alias
+	converted := SSHConfigConverter(&config.SSHConfig{Host: "web"})
+	if converted.Host != "web.internal" || converted.User != "deploy" || converted.Port != 2222 {
+		t.Errorf("Unexpected converted config: %+v", converted)
+	}
+	if converted.KeyPath != filepath.Join(sshDir, "prod") {
+		t.Errorf("Expected the first existing identity file, got %s", converted.KeyPath)
+	}
+	if len(converted.ProxyJump) != 1 {
+		t.Fatalf("Expected 1 jump host, got %d", len(converted.ProxyJump))
+	}
+	hop := converted.ProxyJump[0]
+	if hop.Host != "bastion.example.com" || hop.User != "ops" || hop.Port != 2200 || hop.KeyPath != filepath.Join(sshDir, "bastion") {
+		t.Errorf("Unexpected jump host: %+v", hop)
+	}
+	if len(hop.ProxyJump) != 0 {
+		t.Errorf("Expected jump hosts to have no jump hosts of their own, got %d", len(hop.ProxyJump))
+	}
+
+	// Explicit settings take precedence
+	converted = SSHConfigConverter(&config.SSHConfig{
+		Host:      "web",
+		User:      "admin",
+		Port:      22,
+		ProxyJump: []config.SSHConfig{{Host: "gateway"}},
+	})
+	if converted.Host != "web.internal" || converted.User != "admin" || converted.Port != 22 {
+		t.Errorf("Unexpected converted config: %+v", converted)
+	}
+	if len(converted.ProxyJump) != 1 || converted.ProxyJump[0].Host != "gateway" {
+		t.Errorf("Expected configured jump hosts to replace those of ~/.ssh/config, got %+v", converted.ProxyJump)
+	}
+}
@@ -0,0 +1,340 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Default settings of a connection pool
+const (
+	DefaultKeepAlive   = 30 * time.Second
+	DefaultIdleTimeout = 5 * time.Minute
+	DefaultMaxSessions = 10
+)
+
+// PoolOptions controls how a Pool manages its connections. Zero fields take
+// the defaults.
+type PoolOptions struct {
+	// KeepAlive is the interval between keepalive requests. A connection
+	// that does not answer within the interval is dropped.
+	KeepAlive time.Duration
+
+	// IdleTimeout closes connections without sessions for this long
+	IdleTimeout time.Duration
+
+	// MaxSessions is the number of sessions a connection may have open at
+	// once. Further sessions wait for one to close.
+	MaxSessions int
+}
+
+// Pool shares SSH connections between executors, one connection per key.
+// Connections are dialed on first use, kept alive, reconnected when their
+// transport is lost and closed when idle. A Pool is safe for concurrent use.
+type Pool struct {
+	options PoolOptions
+
+	mu     sync.Mutex
+	conns  map[string]*poolConn
+	closed bool
+}
+
+// poolConn is a pooled connection
+type poolConn struct {
+	pool   *Pool
+	key    string
+	config *SSHConfig
+	client *ssh.Client
+	jumps  []*ssh.Client
+
+	// ready is closed once dialing finished, with err set on failure
+	ready chan struct{}
+	err   error
+
+	// slots holds one element for each open session
+	slots chan struct{}
+
+	// dead is closed when the transport of a dialed connection ends
+	dead chan struct{}
+
+	mu       sync.Mutex
+	active   int
+	lastUsed time.Time
+	// retired connections are out of the pool and close once their last
+	// session is released
+	retired bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPool creates a connection pool
+func NewPool(options PoolOptions) *Pool {
+	if options.KeepAlive <= 0 {
+		options.KeepAlive = DefaultKeepAlive
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = DefaultIdleTimeout
+	}
+	if options.MaxSessions <= 0 {
+		options.MaxSessions = DefaultMaxSessions
+	}
+	return &Pool{
+		options: options,
+		conns:   make(map[string]*poolConn),
+	}
+}
+
+// Session opens a session on the connection for key, dialing it with config
+// if needed. A connection whose transport was lost is reconnected once. The
+// returned function must be called after the session is closed.
+func (p *Pool) Session(key string, config *SSHConfig) (*ssh.Session, func(), error) {
+	var session *ssh.Session
+	release, err := p.open(key, config, func(client *ssh.Client) (err error) {
+		if session, err = client.NewSession(); err != nil {
+			return fmt.Errorf("failed to create ssh session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, release, nil
+}
+
+// open runs open, which opens a session or another channel, on the
+// connection for key. When open fails because the transport was lost, the
+// connection is dropped and dialed again once; other failures, such as a
+// channel refused by the server, leave it in place. The returned function
+// must be called after the channel is closed.
+func (p *Pool) open(key string, config *SSHConfig, open func(*ssh.Client) error) (func(), error) {
+	for attempt := 0; ; {
+		c, err := p.conn(key, config)
+		if err != nil {
+			return nil, err
+		}
+
+		// The connection may have been retired since it was looked up
+		if !c.acquire() {
+			continue
+		}
+		err = open(c.client)
+		if err == nil {
+			var once sync.Once
+			return func() { once.Do(c.release) }, nil
+		}
+		c.release()
+
+		var refused *ssh.OpenChannelError
+		if errors.As(err, &refused) || !c.broken() {
+			return nil, err
+		}
+		p.remove(c)
+		if attempt++; attempt > 1 {
+			return nil, err
+		}
+	}
+}
+
+// Close closes every connection of the pool, ending the sessions still open.
+// Sessions can no longer be opened afterwards.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*poolConn)
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+	return nil
+}
+
+// conn returns the connection for key, dialing it when there is none or when
+// its configuration changed
+func (p *Pool) conn(key string, config *SSHConfig) (*poolConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("connection pool is closed")
+	}
+	if c, ok := p.conns[key]; ok {
+		if reflect.DeepEqual(c.config, config) {
+			p.mu.Unlock()
+			// Wait for a connection being dialed by another caller
+			<-c.ready
+			if c.err != nil {
+				return nil, c.err
+			}
+			return c, nil
+		}
+		delete(p.conns, key)
+		defer c.retire()
+	}
+
+	c := &poolConn{
+		pool:     p,
+		key:      key,
+		config:   config,
+		ready:    make(chan struct{}),
+		slots:    make(chan struct{}, p.options.MaxSessions),
+		dead:     make(chan struct{}),
+		lastUsed: time.Now(),
+		done:     make(chan struct{}),
+	}
+	p.conns[key] = c
+	p.mu.Unlock()
+
+	// Dial without holding the lock, so other keys are not blocked
+	c.client, c.jumps, c.err = dial(config)
+	close(c.ready)
+	if c.err != nil {
+		p.remove(c)
+		return nil, c.err
+	}
+
+	go func() {
+		c.client.Wait()
+		close(c.dead)
+	}()
+	go c.maintain()
+	return c, nil
+}
+
+// remove drops a connection from the pool. It is closed once its sessions
+// are released.
+func (p *Pool) remove(c *poolConn) {
+	p.mu.Lock()
+	if p.conns[c.key] == c {
+		delete(p.conns, c.key)
+	}
+	p.mu.Unlock()
+	c.retire()
+}
+
+// retire marks a connection as out of the pool and closes it when it has no
+// sessions
+func (c *poolConn) retire() {
+	c.mu.Lock()
+	c.retired = true
+	unused := c.active == 0
+	c.mu.Unlock()
+	if unused {
+		c.close()
+	}
+}
+
+// acquire waits for a free session slot. It returns false when the
+// connection was retired.
+func (c *poolConn) acquire() bool {
+	c.slots <- struct{}{}
+	c.mu.Lock()
+	if c.retired {
+		c.mu.Unlock()
+		<-c.slots
+		return false
+	}
+	c.active++
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+	return true
+}
+
+// release frees a session slot, closing a retired connection after its last
+// session
+func (c *poolConn) release() {
+	c.mu.Lock()
+	c.active--
+	c.lastUsed = time.Now()
+	unused := c.retired && c.active == 0
+	c.mu.Unlock()
+	<-c.slots
+	if unused {
+		c.close()
+	}
+}
+
+// broken reports whether the transport of the connection was lost, either
+// because it ended or because it does not answer a keepalive
+func (c *poolConn) broken() bool {
+	select {
+	case <-c.dead:
+		return true
+	default:
+	}
+	return c.keepAlive() != nil
+}
+
+// idle reports whether the connection has had no sessions for longer than
+// the idle timeout
+func (c *poolConn) idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active == 0 && time.Since(c.lastUsed) > c.pool.options.IdleTimeout
+}
+
+// maintain sends keepalives and drops the connection once it is idle or
+// broken
+func (c *poolConn) maintain() {
+	ticker := time.NewTicker(c.pool.options.KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.dead:
+			c.pool.remove(c)
+			return
+		case <-ticker.C:
+		}
+
+		if c.idle() {
+			c.pool.remove(c)
+			return
+		}
+		if err := c.keepAlive(); err != nil {
+			c.pool.remove(c)
+			return
+		}
+	}
+}
+
+// keepAlive sends a keepalive request and waits for the reply
+func (c *poolConn) keepAlive() error {
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+		errc <- err
+	}()
+
+	timer := time.NewTimer(c.pool.options.KeepAlive)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("keepalive timed out after %s", c.pool.options.KeepAlive)
+	}
+}
+
+// close closes the connection and its jump host connections, once dialing
+// finished
+func (c *poolConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.ready
+		if c.client == nil {
+			return
+		}
+		c.client.Close()
+		for i := len(c.jumps) - 1; i >= 0; i-- {
+			c.jumps[i].Close()
+		}
+	})
+}
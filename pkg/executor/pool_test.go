@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// poolSize returns the number of connections of a pool
+func poolSize(p *Pool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// runPooled runs a command with a pooled executor
+func runPooled(t *testing.T, pool *Pool, server *testSSHServer, command ...string) error {
+	t.Helper()
+	exec, err := NewPooledSSHExecutor(pool, "app1", server.sshConfig(), NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}))
+	if err != nil {
+		t.Fatalf("NewPooledSSHExecutor failed: %v", err)
+	}
+	defer exec.Close()
+	return exec.Execute(command)
+}
+
+func TestPool(t *testing.T) {
+	server := startTestSSHServer(t)
+	pool := NewPool(PoolOptions{})
+	defer pool.Close()
+
+	// Executors share one connection, dialed on first use
+	if server.connections() != 0 {
+		t.Fatalf("Expected no connection before the first command")
+	}
+	for i := 0; i < 3; i++ {
+		if err := runPooled(t, pool, server, "true"); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+	if server.connections() != 1 {
+		t.Errorf("Expected 1 connection, got %d", server.connections())
+	}
+
+	// A broken connection is replaced transparently
+	server.dropConnections()
+	if err := runPooled(t, pool, server, "true"); err != nil {
+		t.Fatalf("Expected the pool to reconnect, got %v", err)
+	}
+	if server.connections() != 2 {
+		t.Errorf("Expected 2 connections after reconnecting, got %d", server.connections())
+	}
+
+	// A changed configuration replaces the connection
+	config := server.sshConfig()
+	config.Timeout = time.Second
+	exec, _ := NewPooledSSHExecutor(pool, "app1", config, NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}))
+	if err := exec.Execute([]string{"true"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	exec.Close()
+	if server.connections() != 3 || poolSize(pool) != 1 {
+		t.Errorf("Expected the connection to be replaced, got %d connections and pool size %d", server.connections(), poolSize(pool))
+	}
+
+	pool.Close()
+	if err := runPooled(t, pool, server, "true"); err == nil {
+		t.Errorf("Expected a closed pool to refuse sessions")
+	}
+}
+
+func TestPoolMaxSessions(t *testing.T) {
+	server := startTestSSHServer(t)
+	pool := NewPool(PoolOptions{MaxSessions: 1})
+	defer pool.Close()
+
+	// With one session per connection, commands run one after the other
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runPooled(t, pool, server, "sleep", "0.2"); err != nil {
+				t.Errorf("Execute failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected sessions to wait for each other, took %s", elapsed)
+	}
+	if server.connections() != 1 {
+		t.Errorf("Expected 1 connection, got %d", server.connections())
+	}
+}
+
+func TestPoolOpenFailures(t *testing.T) {
+	server := startTestSSHServer(t)
+	pool := NewPool(PoolOptions{})
+	defer pool.Close()
+
+	// A channel refused by the server leaves the connection in place
+	_, err := pool.open("app1", server.sshConfig(), func(client *ssh.Client) error {
+		_, _, err := client.OpenChannel("unsupported@example.com", nil)
+		return err
+	})
+	var refused *ssh.OpenChannelError
+	if !errors.As(err, &refused) {
+		t.Fatalf("Expected the channel to be refused, got %v", err)
+	}
+	if err := runPooled(t, pool, server, "true"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if server.connections() != 1 || poolSize(pool) != 1 {
+		t.Errorf("Expected the connection to be kept, got %d connections and pool size %d", server.connections(), poolSize(pool))
+	}
+
+	// A replaced connection stays open for the sessions still using it
+	session, release, err := pool.Session("app1", server.sshConfig())
+	if err != nil {
+		t.Fatalf("Session failed: %v", err)
+	}
+	defer release()
+	config := server.sshConfig()
+	config.Timeout = time.Second
+	exec, _ := NewPooledSSHExecutor(pool, "app1", config, NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{}))
+	if err := exec.Execute([]string{"true"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	exec.Close()
+	if server.connections() != 2 {
+		t.Errorf("Expected the connection to be replaced, got %d connections", server.connections())
+	}
+	if err := session.Run("true"); err != nil {
+		t.Errorf("Expected the session on the replaced connection to run, got %v", err)
+	}
+}
+
+func TestPoolKeepAliveAndIdle(t *testing.T) {
+	server := startTestSSHServer(t)
+
+	// Broken connections are noticed by keepalives and dropped
+	pool := NewPool(PoolOptions{KeepAlive: 20 * time.Millisecond})
+	defer pool.Close()
+	if err := runPooled(t, pool, server, "true"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	server.dropConnections()
+	waitFor(t, func() bool { return poolSize(pool) == 0 }, "the broken connection to be dropped")
+
+	// Idle connections are closed
+	idlePool := NewPool(PoolOptions{KeepAlive: 20 * time.Millisecond, IdleTimeout: 50 * time.Millisecond})
+	defer idlePool.Close()
+	if err := runPooled(t, idlePool, server, "true"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if poolSize(idlePool) != 1 {
+		t.Fatalf("Expected 1 pooled connection, got %d", poolSize(idlePool))
+	}
+	waitFor(t, func() bool { return poolSize(idlePool) == 0 }, "the idle connection to be closed")
+}
+
+// waitFor waits up to two seconds for a condition
+func waitFor(t *testing.T, condition func() bool, description string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// RolloutOptions controls how a command is rolled out across targets in
+// batches
+type RolloutOptions struct {
+	// FanOutOptions control how each batch runs
+	FanOutOptions
+
+	// BatchSize is the number of targets in each batch. BatchPercent sets it
+	// as a percentage of the targets instead. When neither is set, each
+	// batch holds one target.
+	BatchSize    int
+	BatchPercent int
+
+	// Pause is the time to wait between batches
+	Pause time.Duration
+
+	// HealthCheck is a command that must succeed on every target of a batch
+	// before the next batch starts
+	HealthCheck []string
+
+	// HealthCheckEnvironment is the environment the health check runs in
+	HealthCheckEnvironment *Environment
+}
+
+// RolloutResult is the outcome of a rollout
+type RolloutResult struct {
+	// Results holds the result of the command on each target, in the order
+	// of targets. Targets of batches not started are marked skipped.
+	Results []ExecutionResult
+
+	// HealthChecks holds the results of the health checks that ran
+	HealthChecks []ExecutionResult
+
+	// Batches is the number of batches started
+	Batches int
+
+	// Err is the reason the rollout stopped early, or nil when every batch
+	// succeeded
+	Err error
+}
+
+// Batches splits targets into batches of size targets, or of percent
+// percent of the targets, rounded up
+func Batches(targets []string, size, percent int) [][]string {
+	if percent > 0 {
+		size = (len(targets)*percent + 99) / 100
+	}
+	if size <= 0 {
+		size = 1
+	}
+
+	var batches [][]string
+	for start := 0; start < len(targets); start += size {
+		end := start + size
+		if end > len(targets) {
+			end = len(targets)
+		}
+		batches = append(batches, targets[start:end])
+	}
+	return batches
+}
+
+// Rollout runs a command on targets batch by batch. It stops after a batch
+// in which the command or the health check did not succeed on every target.
+func (r *Registry) Rollout(targets []string, command []string, options RolloutOptions) RolloutResult {
+	stdout := options.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	batches := Batches(targets, options.BatchSize, options.BatchPercent)
+	var result RolloutResult
+
+	for i, batch := range batches {
+		if i > 0 && options.Pause > 0 {
+			fmt.Fprintf(stdout, "Pausing %s before the next batch\n", options.Pause)
+			time.Sleep(options.Pause)
+		}
+
+		fmt.Fprintf(stdout, "Batch %d/%d: %s\n", i+1, len(batches), strings.Join(batch, ", "))
+		results := r.FanOut(batch, command, options.FanOutOptions)
+		result.Results = append(result.Results, results...)
+		result.Batches++
+
+		if failed := failedTargets(results); len(failed) > 0 {
+			result.Err = fmt.Errorf("batch %d/%d did not succeed on %s", i+1, len(batches), strings.Join(failed, ", "))
+			break
+		}
+
+		if len(options.HealthCheck) > 0 {
+			fmt.Fprintf(stdout, "Health check for batch %d/%d\n", i+1, len(batches))
+			checkOptions := options.FanOutOptions
+			checkOptions.Environment = options.HealthCheckEnvironment
+			checks := r.FanOut(batch, options.HealthCheck, checkOptions)
+			result.HealthChecks = append(result.HealthChecks, checks...)
+
+			if failed := failedTargets(checks); len(failed) > 0 {
+				result.Err = fmt.Errorf("health check after batch %d/%d did not succeed on %s", i+1, len(batches), strings.Join(failed, ", "))
+				break
+			}
+		}
+	}
+
+	// Targets of the remaining batches were never started
+	for _, batch := range batches[result.Batches:] {
+		for _, target := range batch {
+			result.Results = append(result.Results, ExecutionResult{Target: target, Skipped: true})
+		}
+	}
+	return result
+}
+
+// failedTargets returns the targets whose result did not succeed
+func failedTargets(results []ExecutionResult) []string {
+	var failed []string
+	for _, result := range results {
+		if !result.Succeeded() {
+			failed = append(failed, result.Target)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+func TestBatches(t *testing.T) {
+	targets := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		size, percent int
+		expected      string
+	}{
+		{size: 2, expected: "a,b|c,d|e"},
+		{size: 10, expected: "a,b,c,d,e"},
+		{percent: 40, expected: "a,b|c,d|e"},
+		{percent: 50, expected: "a,b,c|d,e"},
+		{percent: 1, expected: "a|b|c|d|e"},
+		{expected: "a|b|c|d|e"},
+	}
+
+	for _, test := range tests {
+		var parts []string
+		for _, batch := range Batches(targets, test.size, test.percent) {
+			parts = append(parts, strings.Join(batch, ","))
+		}
+		if got := strings.Join(parts, "|"); got != test.expected {
+			t.Errorf("Expected batches %s for size %d and percent %d, got %s", test.expected, test.size, test.percent, got)
+		}
+	}
+}
+
+func TestRollout(t *testing.T) {
+	server := startTestSSHServer(t)
+	targets := make(map[string]config.Target)
+	var names []string
+	for i := 1; i <= 6; i++ {
+		name := fmt.Sprintf("app%d", i)
+		targets[name] = testTarget(server, nil)
+		names = append(names, name)
+	}
+	registry := NewRegistry(targets, nil)
+
+	// The command appends a line to a file and the health check fails once
+	// the file holds more than two lines, so only the first batch is healthy
+	file := filepath.Join(t.TempDir(), "restarts")
+	result := registry.Rollout(names, []string{"echo restarted >> " + file}, RolloutOptions{
+		FanOutOptions: FanOutOptions{Stdout: &syncBuffer{}},
+		BatchSize:     2,
+		HealthCheck:   []string{"test $(wc -l < " + file + ") -le 2"},
+	})
+
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "health check after batch 2/3") {
+		t.Fatalf("Expected the health check of batch 2 to stop the rollout, got %v", result.Err)
+	}
+	if result.Batches != 2 || len(result.HealthChecks) != 4 {
+		t.Errorf("Expected 2 batches and 4 health checks, got %d and %d", result.Batches, len(result.HealthChecks))
+	}
+	if summary := Summarize(result.Results); summary != (Summary{Succeeded: 4, Skipped: 2}) {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	if result.Results[4].Target != "app5" || !result.Results[4].Skipped {
+		t.Errorf("Expected app5 to be skipped, got %+v", result.Results[4])
+	}
+
+	// A failing batch stops the rollout before its health check
+	targets["down"] = unreachableTarget(nil)
+	result = registry.Rollout([]string{"app1", "down", "app2"}, []string{"true"}, RolloutOptions{
+		FanOutOptions: FanOutOptions{Stdout: &syncBuffer{}},
+		HealthCheck:   []string{"true"},
+	})
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "batch 2/3 did not succeed on down") {
+		t.Errorf("Expected batch 2 to stop the rollout, got %v", result.Err)
+	}
+	if len(result.HealthChecks) != 1 {
+		t.Errorf("Expected 1 health check, got %d", len(result.HealthChecks))
+	}
+	if summary := Summarize(result.Results); summary != (Summary{Succeeded: 1, Failed: 1, Skipped: 1}) {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+}
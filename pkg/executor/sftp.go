@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// posixRename is the SFTP extension that renames over an existing file
+const posixRename = "posix-rename@openssh.com"
+
+// Upload copies the local file src to dst on the remote server over SFTP,
+// keeping its permissions. The file is written next to dst under a temporary
+// name and renamed over it once complete.
+func (e *SSHExecutor) Upload(src, dst string, options TransferOptions) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", src)
+	}
+	if err := options.checkSize(src, info.Size()); err != nil {
+		return 0, err
+	}
+
+	client, err := e.newSFTPClient()
+	if err != nil {
+		return 0, err
+	}
+	defer e.closeSession(client)
+
+	tmp := path.Join(path.Dir(dst), "."+path.Base(dst)+".tmp-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	out, err := client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create remote file in %s: %w", path.Dir(dst), err)
+	}
+	n, err := options.copyLimited(src, out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if chmodErr := client.Chmod(tmp, info.Mode().Perm()); chmodErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot set permissions of %s: %v\n", dst, chmodErr)
+		}
+		if _, ok := client.HasExtension(posixRename); ok {
+			err = client.PosixRename(tmp, dst)
+		} else {
+			err = client.Rename(tmp, dst)
+		}
+	}
+	if err != nil {
+		client.Remove(tmp)
+		return n, fmt.Errorf("failed to upload %s to %s: %w", src, dst, err)
+	}
+	return n, nil
+}
+
+// Download copies the file src on the remote server to the local file dst
+// over SFTP, keeping its permissions. dst is only replaced once the whole
+// file was received.
+func (e *SSHExecutor) Download(src, dst string, options TransferOptions) (int64, error) {
+	client, err := e.newSFTPClient()
+	if err != nil {
+		return 0, err
+	}
+	defer e.closeSession(client)
+
+	in, err := client.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open remote file %s: %w", src, err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat remote file %s: %w", src, err)
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", src)
+	}
+	if err := options.checkSize(src, info.Size()); err != nil {
+		return 0, err
+	}
+
+	n, err := writeLocalFile(dst, info.Mode().Perm(), func(out io.Writer) (int64, error) {
+		return options.copyLimited(src, out, in)
+	})
+	if err != nil {
+		return n, fmt.Errorf("failed to download %s to %s: %w", src, dst, err)
+	}
+	return n, nil
+}
+
+// newSFTPClient starts an SFTP client on the executor's client or pooled
+// connection. It must be closed with closeSession.
+func (e *SSHExecutor) newSFTPClient() (*sftp.Client, error) {
+	var client *sftp.Client
+	start := func(conn *ssh.Client) (err error) {
+		if client, err = sftp.NewClient(conn); err != nil {
+			return fmt.Errorf("failed to start sftp: %w", err)
+		}
+		return nil
+	}
+
+	release := func() {}
+	switch {
+	case e.pool != nil:
+		var err error
+		if release, err = e.pool.open(e.poolKey, e.config, start); err != nil {
+			return nil, err
+		}
+	case e.client != nil:
+		if err := start(e.client); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("ssh client is not connected")
+	}
+
+	e.track(client, release)
+	return client, nil
+}
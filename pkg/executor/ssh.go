@@ -3,11 +3,14 @@ package executor
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"net"
 	"os"
-	"strings"
+	"strconv"
+	"sync"
 
+	"github.com/takutakahashi/operation-mcp/pkg/secrets"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SSHExecutor implements the Executor interface for remote command execution via SSH
@@ -15,10 +18,62 @@ type SSHExecutor struct {
 	client  *ssh.Client
 	config  *SSHConfig
 	options *Options
+
+	// jumps holds the connections to the jump hosts, in order
+	jumps []*ssh.Client
+
+	// pool and poolKey are set when the executor uses a pooled connection
+	// instead of its own client
+	pool    *Pool
+	poolKey string
+
+	// sessions holds the open sessions and SFTP clients, which Close ends,
+	// with the functions releasing them
+	mu       sync.Mutex
+	sessions map[io.Closer]func()
 }
 
 // NewSSHExecutor creates a new SSHExecutor with the given configuration
 func NewSSHExecutor(config *SSHConfig, options *Options) (*SSHExecutor, error) {
+	options, err := sshExecutorOptions(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect to the SSH server, through the jump hosts if any
+	client, jumps, err := dial(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSHExecutor{
+		client:   client,
+		config:   config,
+		options:  options,
+		jumps:    jumps,
+		sessions: make(map[io.Closer]func()),
+	}, nil
+}
+
+// NewPooledSSHExecutor creates an SSHExecutor that runs commands on the
+// pool's connection for key, which is dialed on first use
+func NewPooledSSHExecutor(pool *Pool, key string, config *SSHConfig, options *Options) (*SSHExecutor, error) {
+	options, err := sshExecutorOptions(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSHExecutor{
+		config:   config,
+		options:  options,
+		pool:     pool,
+		poolKey:  key,
+		sessions: make(map[io.Closer]func()),
+	}, nil
+}
+
+// sshExecutorOptions validates config and sets default values for options
+func sshExecutorOptions(config *SSHConfig, options *Options) (*Options, error) {
 	if config == nil {
 		return nil, fmt.Errorf("ssh config is required")
 	}
@@ -41,125 +96,107 @@ func NewSSHExecutor(config *SSHConfig, options *Options) (*SSHExecutor, error) {
 	if options.Stderr == nil {
 		options.Stderr = os.Stderr
 	}
+	return options, nil
+}
 
-	// Create SSH client configuration
-	sshConfig, err := createSSHConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create ssh client config: %w", err)
-	}
-
-	// Connect to the SSH server
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+// createSSHConfig creates an SSH client configuration from SSHConfig. The
+// returned function releases resources used for authentication, such as the
+// connection to the ssh-agent, and must be called once connected.
+func createSSHConfig(config *SSHConfig) (*ssh.ClientConfig, func(), error) {
+	// Verify the host key according to the host key policy. Verification
+	// failures are fatal.
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	callback, algorithms, err := hostKeyCallback(config, addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ssh server %s: %w", addr, err)
+		return nil, nil, err
 	}
 
-	return &SSHExecutor{
-		client:  client,
-		config:  config,
-		options: options,
-	}, nil
-}
-
-// createSSHConfig creates an SSH client configuration from SSHConfig
-func createSSHConfig(config *SSHConfig) (*ssh.ClientConfig, error) {
 	sshConfig := &ssh.ClientConfig{
-		User:            config.User,
-		Timeout:         config.Timeout,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	// If host key verification is enabled, use the known_hosts file
-	if config.VerifyHost && config.HostKeyPath != "" {
-		hostKeyCallback, err := knownhosts.New(config.HostKeyPath)
-		if err != nil {
-			// If we can't read the known_hosts file, log a warning but continue
-			fmt.Fprintf(os.Stderr, "Warning: cannot use known_hosts file %s: %v\n", config.HostKeyPath, err)
-		} else {
-			sshConfig.HostKeyCallback = hostKeyCallback
-		}
+		User:              config.User,
+		Timeout:           config.Timeout,
+		HostKeyCallback:   callback,
+		HostKeyAlgorithms: algorithms,
 	}
 
 	// Set up authentication methods
 	var authMethods []ssh.AuthMethod
 
-	// Try key-based authentication first if a key path is provided
-	if config.KeyPath != "" {
-		keyAuth, err := publicKeyFile(config.KeyPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: cannot use key file %s: %v\n", config.KeyPath, err)
-		} else {
-			authMethods = append(authMethods, keyAuth)
-		}
+	// Try key-based authentication first, with the key file and the keys of
+	// the ssh-agent. They form one method, since the client tries each
+	// method only once.
+	signers, cleanup := publicKeySigners(config)
+	if len(signers) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
 	}
 
-	// Add password authentication if provided
+	// Add password authentication if provided. The password may be a secret
+	// reference, which is resolved only now.
 	if config.Password != "" {
-		authMethods = append(authMethods, ssh.Password(config.Password))
+		password, err := secrets.Resolve(config.Password)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to resolve ssh password: %w", err)
+		}
+		authMethods = append(authMethods, ssh.Password(password))
 	}
 
 	// If no auth methods are available, return an error
 	if len(authMethods) == 0 {
-		return nil, fmt.Errorf("no authentication methods available")
+		cleanup()
+		return nil, nil, fmt.Errorf("no authentication methods available")
 	}
 
 	sshConfig.Auth = authMethods
-	return sshConfig, nil
-}
-
-// publicKeyFile creates an AuthMethod from a private key file
-func publicKeyFile(file string) (ssh.AuthMethod, error) {
-	buffer, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
-	}
-
-	key, err := ssh.ParsePrivateKey(buffer)
-	if err != nil {
-		return nil, err
-	}
-
-	return ssh.PublicKeys(key), nil
+	return sshConfig, cleanup, nil
 }
 
 // Execute runs a command on the remote server and connects its stdout/stderr to the current process
 func (e *SSHExecutor) Execute(command []string) error {
-	if e.client == nil {
-		return fmt.Errorf("ssh client is not connected")
+	return e.ExecuteIn(e.options.Environment, command)
+}
+
+// ExecuteIn runs a command on the remote server in env and connects its
+// stdout/stderr to the current process
+func (e *SSHExecutor) ExecuteIn(env *Environment, command []string) error {
+	// A command on a pseudo-terminal needs the local terminal
+	fd := -1
+	if env != nil && env.TTY {
+		var err error
+		if fd, err = terminalFd(e.options.Stdin); err != nil {
+			return err
+		}
 	}
 
 	// Create a new SSH session
-	session, err := e.client.NewSession()
+	session, err := e.newSession()
 	if err != nil {
-		return fmt.Errorf("failed to create ssh session: %w", err)
+		return err
 	}
-	defer session.Close()
+	defer e.closeSession(session)
 
 	// Set up IO
 	session.Stdin = e.options.Stdin
 	session.Stdout = e.options.Stdout
 	session.Stderr = e.options.Stderr
 
-	// Convert command slice to string
-	cmdStr := strings.Join(command, " ")
+	// Convert command slice to string, in the environment
+	cmdStr := env.remoteCommand(session, command)
 
 	// Run the command
+	if fd >= 0 {
+		return runOnTerminal(session, fd, cmdStr)
+	}
 	return session.Run(cmdStr)
 }
 
 // ExecuteWithOutput runs a command on the remote server and returns its combined output
 func (e *SSHExecutor) ExecuteWithOutput(command []string) (string, error) {
-	if e.client == nil {
-		return "", fmt.Errorf("ssh client is not connected")
-	}
-
 	// Create a new SSH session
-	session, err := e.client.NewSession()
+	session, err := e.newSession()
 	if err != nil {
-		return "", fmt.Errorf("failed to create ssh session: %w", err)
+		return "", err
 	}
-	defer session.Close()
+	defer e.closeSession(session)
 
 	// Set up buffers for output
 	var stdout, stderr bytes.Buffer
@@ -167,8 +204,8 @@ func (e *SSHExecutor) ExecuteWithOutput(command []string) (string, error) {
 	session.Stderr = &stderr
 	session.Stdin = e.options.Stdin
 
-	// Convert command slice to string
-	cmdStr := strings.Join(command, " ")
+	// Convert command slice to string, in the environment
+	cmdStr := e.options.Environment.remoteCommand(session, command)
 
 	// Run the command
 	err = session.Run(cmdStr)
@@ -180,18 +217,80 @@ func (e *SSHExecutor) ExecuteWithOutput(command []string) (string, error) {
 	return stdout.String(), nil
 }
 
-// Close closes the SSH connection
+// newSession opens a session on the executor's client or pooled connection
+func (e *SSHExecutor) newSession() (*ssh.Session, error) {
+	var session *ssh.Session
+	release := func() {}
+	switch {
+	case e.pool != nil:
+		var err error
+		if session, release, err = e.pool.Session(e.poolKey, e.config); err != nil {
+			return nil, err
+		}
+	case e.client != nil:
+		var err error
+		if session, err = e.client.NewSession(); err != nil {
+			return nil, fmt.Errorf("failed to create ssh session: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("ssh client is not connected")
+	}
+
+	e.track(session, release)
+	return session, nil
+}
+
+// track records an open session or SFTP client with the function releasing
+// it
+func (e *SSHExecutor) track(session io.Closer, release func()) {
+	e.mu.Lock()
+	e.sessions[session] = release
+	e.mu.Unlock()
+}
+
+// closeSession closes a session or SFTP client opened by the executor and
+// releases it
+func (e *SSHExecutor) closeSession(session io.Closer) {
+	e.mu.Lock()
+	release := e.sessions[session]
+	delete(e.sessions, session)
+	e.mu.Unlock()
+
+	session.Close()
+	if release != nil {
+		release()
+	}
+}
+
+// Close ends the open sessions and closes the SSH connection and the
+// connections to the jump hosts. A pooled connection stays open for other
+// executors.
 func (e *SSHExecutor) Close() error {
+	// Running commands return once their session is closed, and release it
+	e.mu.Lock()
+	for session := range e.sessions {
+		session.Close()
+	}
+	e.mu.Unlock()
+
+	var err error
 	if e.client != nil {
-		return e.client.Close()
+		err = e.client.Close()
 	}
-	return nil
+	for i := len(e.jumps) - 1; i >= 0; i-- {
+		e.jumps[i].Close()
+	}
+	return err
 }
 
 // SSHExecutorFactory creates SSH executors
 type SSHExecutorFactory struct {
 	config  *SSHConfig
 	options *Options
+
+	// pool and poolKey are set for factories of pooled executors
+	pool    *Pool
+	poolKey string
 }
 
 // NewSSHExecutorFactory creates a new factory for SSH executors
@@ -202,7 +301,21 @@ func NewSSHExecutorFactory(config *SSHConfig, options *Options) *SSHExecutorFact
 	}
 }
 
+// NewPooledSSHExecutorFactory creates a new factory for SSH executors that
+// share the pool's connection for key
+func NewPooledSSHExecutorFactory(pool *Pool, key string, config *SSHConfig, options *Options) *SSHExecutorFactory {
+	return &SSHExecutorFactory{
+		config:  config,
+		options: options,
+		pool:    pool,
+		poolKey: key,
+	}
+}
+
 // CreateExecutor creates a new SSHExecutor
 func (f *SSHExecutorFactory) CreateExecutor() (Executor, error) {
+	if f.pool != nil {
+		return NewPooledSSHExecutor(f.pool, f.poolKey, f.config, f.options)
+	}
 	return NewSSHExecutor(f.config, f.options)
 }
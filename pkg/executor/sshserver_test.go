@@ -0,0 +1,311 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testPassword is the password accepted by the test SSH server
+const testPassword = "secret"
+
+// testSSHServer is an in-process SSH server for tests. It accepts
+// testPassword, authorized keys and certificates signed by a trusted
+// authority. It runs exec requests with sh -c on the local machine and
+// forwards direct-tcpip channels, so it can also serve as a jump host.
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+
+	mu             sync.Mutex
+	authorizedKeys []ssh.PublicKey
+	authority      ssh.PublicKey
+	commands       []string
+	env            map[string]string
+	rejectEnv      bool
+	dials          []string
+	conns          []*ssh.ServerConn
+}
+
+// startTestSSHServer starts a test SSH server that accepts testPassword
+func startTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create host key signer: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	s := &testSSHServer{listener: listener, config: config, hostKey: hostKey, env: make(map[string]string)}
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.authority != nil && bytes.Equal(auth.Marshal(), s.authority.Marshal())
+		},
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, authorized := range s.authorizedKeys {
+				if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.PublicKeyCallback = checker.Authenticate
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// authorize accepts a public key for authentication
+func (s *testSSHServer) authorize(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizedKeys = append(s.authorizedKeys, key)
+}
+
+// trustAuthority accepts user certificates signed by a key
+func (s *testSSHServer) trustAuthority(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authority = key
+}
+
+// host returns the host of the server
+func (s *testSSHServer) host() string {
+	return "127.0.0.1"
+}
+
+// port returns the port of the server
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// sshConfig returns a client config for the server
+func (s *testSSHServer) sshConfig() *SSHConfig {
+	return &SSHConfig{
+		Host:     s.host(),
+		Port:     s.port(),
+		User:     "test",
+		Password: testPassword,
+	}
+}
+
+// executed returns the commands run on the server
+func (s *testSSHServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// dialed returns the addresses the server forwarded connections to
+func (s *testSSHServer) dialed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.dials...)
+}
+
+// connections returns the number of connections accepted so far
+func (s *testSSHServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// dropConnections closes every open connection, as a network failure would
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// serve accepts connections until the listener is closed
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn serves one client connection
+func (s *testSSHServer) handleConn(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.mu.Unlock()
+
+	for newChannel := range channels {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(newChannel)
+		case "direct-tcpip":
+			go s.handleDirectTCPIP(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// handleSession runs the exec request of a session
+func (s *testSSHServer) handleSession(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	env := make(map[string]string)
+	for req := range requests {
+		switch req.Type {
+		case "env":
+			var payload struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			s.mu.Lock()
+			reject := s.rejectEnv
+			if !reject {
+				env[payload.Name] = payload.Value
+				s.env[payload.Name] = payload.Value
+			}
+			s.mu.Unlock()
+			req.Reply(!reject, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+
+			s.mu.Lock()
+			s.commands = append(s.commands, payload.Command)
+			s.mu.Unlock()
+
+			cmd := exec.Command("sh", "-c", payload.Command)
+			for name, value := range env {
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+			cmd.Stdout = channel
+			cmd.Stderr = channel.Stderr()
+
+			status := 0
+			if err := cmd.Run(); err != nil {
+				status = 1
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					status = exitErr.ExitCode()
+				}
+			}
+
+			exitStatus := make([]byte, 4)
+			binary.BigEndian.PutUint32(exitStatus, uint32(status))
+			channel.SendRequest("exit-status", false, exitStatus)
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			server.Close()
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// handleDirectTCPIP forwards a direct-tcpip channel to its destination
+func (s *testSSHServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.dials = append(s.dials, addr)
+	s.mu.Unlock()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	go func() {
+		io.Copy(channel, target)
+		channel.CloseWrite()
+	}()
+	io.Copy(target, channel)
+	target.Close()
+	channel.Close()
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+// Registry resolves target names to executor factories. The local target
+// and the empty name run commands on the local machine.
+type Registry struct {
+	targets map[string]config.Target
+	options *Options
+	pool    *Pool
+}
+
+// NewRegistry creates a registry for the targets defined in the configuration
+func NewRegistry(targets map[string]config.Target, options *Options) *Registry {
+	return &Registry{
+		targets: targets,
+		options: options,
+	}
+}
+
+// WithPool makes the executors of remote targets share the connections of
+// a pool, keyed by target name
+func (r *Registry) WithPool(pool *Pool) *Registry {
+	r.pool = pool
+	return r
+}
+
+// Factory returns the executor factory for a target
+func (r *Registry) Factory(name string) (Factory, error) {
+	return r.FactoryWithOptions(name, r.options)
+}
+
+// FactoryWithOptions returns the executor factory for a target whose
+// executors use the given options
+func (r *Registry) FactoryWithOptions(name string, options *Options) (Factory, error) {
+	if name == "" || name == config.LocalTarget {
+		return NewLocalExecutorFactory(options), nil
+	}
+
+	target, ok := r.targets[name]
+	if !ok {
+		return nil, fmt.Errorf("target not found: %s", name)
+	}
+
+	sshConfig := SSHConfigConverter(&target.SSHConfig)
+	if sshConfig.Host == "" {
+		return nil, fmt.Errorf("target %s has no host", name)
+	}
+	if r.pool != nil {
+		return NewPooledSSHExecutorFactory(r.pool, name, sshConfig, options), nil
+	}
+	return NewSSHExecutorFactory(sshConfig, options), nil
+}
+
+// Select returns the targets matching a selector. A selector is either a
+// comma-separated list of target names, returned in the given order, or a
+// comma-separated list of label requirements such as "role=app,env!=prod",
+// all of which must hold, returning the matching targets in sorted order.
+func (r *Registry) Select(selector string) ([]string, error) {
+	var terms []string
+	for _, term := range strings.Split(selector, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty target selector")
+	}
+
+	// A list of names
+	if !strings.Contains(selector, "=") {
+		seen := make(map[string]bool)
+		var names []string
+		for _, name := range terms {
+			if _, ok := r.targets[name]; !ok && name != config.LocalTarget {
+				return nil, fmt.Errorf("target not found: %s", name)
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+
+	// A label query
+	type requirement struct {
+		key, value string
+		negate     bool
+	}
+	var requirements []requirement
+	for _, term := range terms {
+		var req requirement
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			req = requirement{key: strings.TrimSpace(key), value: strings.TrimSpace(value), negate: true}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			req = requirement{key: strings.TrimSpace(key), value: strings.TrimSpace(value)}
+		} else {
+			return nil, fmt.Errorf("invalid label requirement %q in selector %q (target names and labels cannot be mixed)", term, selector)
+		}
+		if req.key == "" {
+			return nil, fmt.Errorf("invalid label requirement %q in selector %q", term, selector)
+		}
+		requirements = append(requirements, req)
+	}
+
+	var names []string
+	for _, name := range r.Names() {
+		labels := r.targets[name].Labels
+		matches := true
+		for _, req := range requirements {
+			value, ok := labels[req.key]
+			if req.negate == (ok && value == req.value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no targets match %s", selector)
+	}
+	return names, nil
+}
+
+// Labels returns the labels of a target
+func (r *Registry) Labels(name string) map[string]string {
+	return r.targets[name].Labels
+}
+
+// Names returns the names of all targets in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.targets))
+	for name := range r.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(map[string]config.Target{
+		"bastion": {
+			SSHConfig: config.SSHConfig{Host: "bastion.example.com", Port: 2222, User: "ops"},
+			Labels:    map[string]string{"role": "bastion"},
+		},
+		"broken": {},
+	}, nil)
+
+	// The local target and the empty name run locally
+	for _, name := range []string{"", config.LocalTarget} {
+		factory, err := registry.Factory(name)
+		if err != nil {
+			t.Fatalf("Factory failed for %q: %v", name, err)
+		}
+		if _, ok := factory.(*LocalExecutorFactory); !ok {
+			t.Errorf("Expected local factory for %q, got %T", name, factory)
+		}
+	}
+
+	factory, err := registry.Factory("bastion")
+	if err != nil {
+		t.Fatalf("Factory failed: %v", err)
+	}
+	sshFactory, ok := factory.(*SSHExecutorFactory)
+	if !ok {
+		t.Fatalf("Expected SSH factory, got %T", factory)
+	}
+	if sshFactory.config.Host != "bastion.example.com" || sshFactory.config.Port != 2222 || sshFactory.config.User != "ops" {
+		t.Errorf("Unexpected ssh config: %+v", sshFactory.config)
+	}
+
+	if _, err := registry.Factory("unknown"); err == nil {
+		t.Errorf("Expected error for unknown target")
+	}
+	if _, err := registry.Factory("broken"); err == nil {
+		t.Errorf("Expected error for target without host")
+	}
+
+	if !reflect.DeepEqual(registry.Names(), []string{"bastion", "broken"}) {
+		t.Errorf("Unexpected names: %v", registry.Names())
+	}
+	if registry.Labels("bastion")["role"] != "bastion" {
+		t.Errorf("Expected label role=bastion, got %v", registry.Labels("bastion"))
+	}
+}
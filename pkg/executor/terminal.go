@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// terminalFd returns the file descriptor of stdin when it is a terminal.
+// Commands needing a terminal are refused otherwise, such as when input is
+// piped.
+func terminalFd(stdin io.Reader) (int, error) {
+	file, ok := stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return 0, fmt.Errorf("tty requires an interactive terminal")
+	}
+	return int(file.Fd()), nil
+}
+
+// runOnTerminal runs a command in a session on a pseudo-terminal with the
+// size of the local terminal fd. Meanwhile the local terminal is in raw mode,
+// so that keys such as Ctrl-C reach the remote command, and its size
+// changes are forwarded.
+func runOnTerminal(session *ssh.Session, fd int, command string) error {
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 80, 24
+	}
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return fmt.Errorf("failed to request pty: %w", err)
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to put terminal in raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	// Forward window size changes until the command exits
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-resize:
+				if width, height, err := term.GetSize(fd); err == nil {
+					session.WindowChange(height, width)
+				}
+			}
+		}
+	}()
+
+	return session.Run(command)
+}
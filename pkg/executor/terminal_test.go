@@ -0,0 +1,32 @@
+package executor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTTYRequiresTerminal(t *testing.T) {
+	server := startTestSSHServer(t)
+	env := &Environment{TTY: true}
+
+	// Input that is not a terminal, as when running non-interactively
+	options := NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{})
+	exec, err := NewSSHExecutor(server.sshConfig(), options)
+	if err != nil {
+		t.Fatalf("NewSSHExecutor failed: %v", err)
+	}
+	defer exec.Close()
+
+	if err := exec.ExecuteIn(env, []string{"top"}); err == nil || !strings.Contains(err.Error(), "interactive terminal") {
+		t.Errorf("Expected error without a terminal, got %v", err)
+	}
+	if executed := server.executed(); len(executed) != 0 {
+		t.Errorf("Expected no command to run, got %v", executed)
+	}
+
+	local := NewLocalExecutor(options)
+	if err := local.ExecuteIn(env, []string{"true"}); err == nil || !strings.Contains(err.Error(), "interactive terminal") {
+		t.Errorf("Expected error without a terminal, got %v", err)
+	}
+}
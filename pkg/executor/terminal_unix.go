@@ -0,0 +1,14 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize relays changes of the local terminal size to c
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package executor
+
+import "os"
+
+// notifyResize does nothing, as Windows has no signal for terminal size
+// changes; the remote terminal keeps its initial size
+func notifyResize(c chan<- os.Signal) {}
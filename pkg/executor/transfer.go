@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Transferrer is implemented by executors that can copy files to and from
+// the host they run commands on
+type Transferrer interface {
+	// Upload copies the local file src to dst on the host and returns the
+	// number of bytes copied
+	Upload(src, dst string, options TransferOptions) (int64, error)
+
+	// Download copies the file src on the host to the local file dst and
+	// returns the number of bytes copied
+	Download(src, dst string, options TransferOptions) (int64, error)
+}
+
+// TransferOptions controls a file transfer
+type TransferOptions struct {
+	// MaxSize is the largest file size in bytes that may be transferred.
+	// Zero means no limit.
+	MaxSize int64
+}
+
+// checkSize returns an error when a file is larger than the size limit
+func (o TransferOptions) checkSize(name string, size int64) error {
+	if o.MaxSize > 0 && size > o.MaxSize {
+		return fmt.Errorf("%s is %d bytes, more than the limit of %d bytes", name, size, o.MaxSize)
+	}
+	return nil
+}
+
+// copyLimited copies src to dst, failing when more than the size limit is
+// read, for files that grow while being copied
+func (o TransferOptions) copyLimited(name string, dst io.Writer, src io.Reader) (int64, error) {
+	if o.MaxSize <= 0 {
+		return io.Copy(dst, src)
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, o.MaxSize+1))
+	if err != nil {
+		return n, err
+	}
+	if n > o.MaxSize {
+		return n, fmt.Errorf("%s grew beyond the limit of %d bytes", name, o.MaxSize)
+	}
+	return n, nil
+}
+
+// Upload copies the local file src to dst
+func (e *LocalExecutor) Upload(src, dst string, options TransferOptions) (int64, error) {
+	return copyFile(src, dst, options)
+}
+
+// Download copies the local file src to dst
+func (e *LocalExecutor) Download(src, dst string, options TransferOptions) (int64, error) {
+	return copyFile(src, dst, options)
+}
+
+// copyFile copies a local file, keeping its permissions. The destination is
+// only replaced once the copy is complete, so src and dst may be the same.
+func copyFile(src, dst string, options TransferOptions) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", src)
+	}
+	if err := options.checkSize(src, info.Size()); err != nil {
+		return 0, err
+	}
+
+	n, err := writeLocalFile(dst, info.Mode().Perm(), func(out io.Writer) (int64, error) {
+		return options.copyLimited(src, out, in)
+	})
+	if err != nil {
+		return n, fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return n, nil
+}
+
+// writeLocalFile writes a local file through a temporary file in the same
+// directory, which replaces dst only when write succeeds. Failing to set the
+// permissions is only a warning.
+func writeLocalFile(dst string, mode os.FileMode, write func(io.Writer) (int64, error)) (int64, error) {
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if chmodErr := os.Chmod(out.Name(), mode); chmodErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot set permissions of %s: %v\n", dst, chmodErr)
+		}
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkFile checks the content and permissions of a file
+func checkFile(t *testing.T, name, content string, mode os.FileMode) {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	if string(data) != content {
+		t.Errorf("Expected %s to contain %q, got %q", name, content, string(data))
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", name, err)
+	}
+	if info.Mode().Perm() != mode {
+		t.Errorf("Expected %s to have mode %v, got %v", name, mode, info.Mode().Perm())
+	}
+}
+
+// checkNoTempFiles checks that no temporary transfer file was left in dir
+func checkNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Expected no temporary files, found %s", entry.Name())
+		}
+	}
+}
+
+func TestLocalExecutorTransfer(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(src, []byte("listen 80\n"), 0o640); err != nil {
+		t.Fatalf("Failed to write %s: %v", src, err)
+	}
+
+	exec := NewLocalExecutor(nil)
+	dst := filepath.Join(dir, "copy.conf")
+	n, err := exec.Upload(src, dst, TransferOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if n != 10 {
+		t.Errorf("Expected 10 bytes copied, got %d", n)
+	}
+	checkFile(t, dst, "listen 80\n", 0o640)
+
+	// Files over the size limit are not copied
+	dst = filepath.Join(dir, "large.conf")
+	if _, err := exec.Download(src, dst, TransferOptions{MaxSize: 9}); err == nil || !strings.Contains(err.Error(), "limit of 9 bytes") {
+		t.Errorf("Expected size limit error, got %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created, got %v", dst, err)
+	}
+
+	// A failed copy leaves an existing destination unchanged
+	dst = filepath.Join(dir, "copy.conf")
+	if _, err := exec.Upload("/dev/zero", dst, TransferOptions{MaxSize: 10}); err == nil || !strings.Contains(err.Error(), "grew beyond") {
+		t.Errorf("Expected size limit error, got %v", err)
+	}
+	checkFile(t, dst, "listen 80\n", 0o640)
+
+	// Copying a file onto itself keeps its content
+	if _, err := exec.Upload(src, src, TransferOptions{}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	checkFile(t, src, "listen 80\n", 0o640)
+	checkNoTempFiles(t, dir)
+}
+
+func TestSSHExecutorTransfer(t *testing.T) {
+	server := startTestSSHServer(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(src, []byte("listen 80\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", src, err)
+	}
+
+	pool := NewPool(PoolOptions{})
+	defer pool.Close()
+	options := NewOptions().WithStdin(strings.NewReader("")).WithStdout(&bytes.Buffer{})
+
+	for name, create := range map[string]func() (*SSHExecutor, error){
+		"direct": func() (*SSHExecutor, error) { return NewSSHExecutor(server.sshConfig(), options) },
+		"pooled": func() (*SSHExecutor, error) { return NewPooledSSHExecutor(pool, "app1", server.sshConfig(), options) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			exec, err := create()
+			if err != nil {
+				t.Fatalf("Failed to create executor: %v", err)
+			}
+			defer exec.Close()
+
+			// The test server serves the local file system
+			remote := filepath.Join(dir, name+"-remote.conf")
+			if _, err := exec.Upload(src, remote, TransferOptions{}); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+			checkFile(t, remote, "listen 80\n", 0o600)
+
+			local := filepath.Join(dir, name+"-local.conf")
+			n, err := exec.Download(remote, local, TransferOptions{MaxSize: 10})
+			if err != nil {
+				t.Fatalf("Download failed: %v", err)
+			}
+			if n != 10 {
+				t.Errorf("Expected 10 bytes copied, got %d", n)
+			}
+			checkFile(t, local, "listen 80\n", 0o600)
+
+			if _, err := exec.Download(remote, local+".large", TransferOptions{MaxSize: 5}); err == nil || !strings.Contains(err.Error(), "limit of 5 bytes") {
+				t.Errorf("Expected size limit error, got %v", err)
+			}
+			if _, err := exec.Download(filepath.Join(dir, "missing"), local, TransferOptions{}); err == nil {
+				t.Errorf("Expected error for a missing remote file")
+			}
+
+			// Failed transfers leave existing files unchanged
+			if _, err := exec.Upload("/dev/zero", remote, TransferOptions{MaxSize: 5}); err == nil || !strings.Contains(err.Error(), "grew beyond") {
+				t.Errorf("Expected size limit error, got %v", err)
+			}
+			checkFile(t, remote, "listen 80\n", 0o600)
+			if _, err := exec.Download("/dev/zero", local, TransferOptions{MaxSize: 5}); err == nil || !strings.Contains(err.Error(), "grew beyond") {
+				t.Errorf("Expected size limit error, got %v", err)
+			}
+			checkFile(t, local, "listen 80\n", 0o600)
+
+			// Existing files are replaced
+			if _, err := exec.Upload(src, local, TransferOptions{}); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+			checkFile(t, local, "listen 80\n", 0o600)
+			checkNoTempFiles(t, dir)
+		})
+	}
+
+	// The pooled executor ran every transfer on one connection
+	if server.connections() != 2 {
+		t.Errorf("Expected 2 connections, got %d", server.connections())
+	}
+}
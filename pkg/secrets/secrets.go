@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in redacted output
+const Mask = "********"
+
+// referencePattern matches secret references such as ${env:SSH_PASS},
+// ${file:/run/secrets/ssh} and ${cmd:pass show ops/ssh}
+var referencePattern = regexp.MustCompile(`\$\{(env|file|cmd):([^}]*)\}`)
+
+// Resolver resolves secret references and remembers the resolved values so
+// they can be redacted from output
+type Resolver struct {
+	mu       sync.Mutex
+	resolved map[string]bool
+
+	lookupEnv  func(string) (string, bool)
+	readFile   func(string) ([]byte, error)
+	runCommand func(string) ([]byte, error)
+}
+
+// NewResolver creates a new resolver reading from the environment, the file
+// system and shell commands
+func NewResolver() *Resolver {
+	return &Resolver{
+		resolved:  make(map[string]bool),
+		lookupEnv: os.LookupEnv,
+		readFile:  os.ReadFile,
+		runCommand: func(command string) ([]byte, error) {
+			return exec.Command("sh", "-c", command).Output()
+		},
+	}
+}
+
+// defaultResolver is used by the package-level functions
+var defaultResolver = NewResolver()
+
+// IsReference reports whether a value contains a secret reference
+func IsReference(value string) bool {
+	return referencePattern.MatchString(value)
+}
+
+// Resolve resolves the secret references in value using the default resolver
+func Resolve(value string) (string, error) {
+	return defaultResolver.Resolve(value)
+}
+
+// Redact masks every secret resolved by the default resolver in text
+func Redact(text string) string {
+	return defaultResolver.Redact(text)
+}
+
+// Resolve replaces every secret reference in value with the secret it points
+// to. Values without references are returned unchanged.
+func (r *Resolver) Resolve(value string) (string, error) {
+	var resolveErr error
+	result := referencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if resolveErr != nil {
+			return ""
+		}
+		m := referencePattern.FindStringSubmatch(ref)
+		secret, err := r.resolveOne(m[1], m[2])
+		if err != nil {
+			resolveErr = err
+			return ""
+		}
+		r.remember(secret)
+		return secret
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return result, nil
+}
+
+// resolveOne resolves a single reference. Errors name the reference but
+// never include the secret.
+func (r *Resolver) resolveOne(kind, arg string) (string, error) {
+	switch kind {
+	case "env":
+		value, ok := r.lookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", arg)
+		}
+		return value, nil
+	case "file":
+		data, err := r.readFile(arg)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret file %s: %w", arg, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "cmd":
+		out, err := r.runCommand(arg)
+		if err != nil {
+			return "", fmt.Errorf("secret command %q failed: %w", arg, err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("unknown secret reference type: %s", kind)
+	}
+}
+
+// remember records a resolved secret for redaction
+func (r *Resolver) remember(secret string) {
+	if secret == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolved[secret] = true
+}
+
+// Redact masks every secret this resolver has resolved in text
+func (r *Resolver) Redact(text string) string {
+	r.mu.Lock()
+	secrets := make([]string, 0, len(r.resolved))
+	for secret := range r.resolved {
+		secrets = append(secrets, secret)
+	}
+	r.mu.Unlock()
+
+	// Replace longer secrets first so a secret containing another is fully masked
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Mask)
+	}
+	return text
+}
@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tempDir := t.TempDir()
+	secretFile := filepath.Join(tempDir, "ssh")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	r := NewResolver()
+	r.lookupEnv = func(name string) (string, bool) {
+		if name == "SSH_PASS" {
+			return "env-secret", true
+		}
+		return "", false
+	}
+	r.runCommand = func(command string) ([]byte, error) {
+		if command == "pass show ops/ssh" {
+			return []byte("cmd-secret\n"), nil
+		}
+		return nil, fmt.Errorf("exit status 1")
+	}
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"${env:SSH_PASS}", "env-secret"},
+		{"${file:" + secretFile + "}", "file-secret"},
+		{"${cmd:pass show ops/ssh}", "cmd-secret"},
+		{"Bearer ${env:SSH_PASS}", "Bearer env-secret"},
+	}
+
+	for _, tt := range tests {
+		got, err := r.Resolve(tt.value)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Resolve(%q): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+
+	// Failures name the reference
+	for _, value := range []string{"${env:MISSING}", "${file:/nonexistent}", "${cmd:false}"} {
+		if _, err := r.Resolve(value); err == nil {
+			t.Errorf("Resolve(%q) should fail", value)
+		}
+	}
+
+	// Resolved secrets are redacted
+	redacted := r.Redact("sshpass -p env-secret ssh host cmd-secret")
+	if strings.Contains(redacted, "env-secret") || strings.Contains(redacted, "cmd-secret") {
+		t.Errorf("Expected secrets to be redacted, got %q", redacted)
+	}
+	if redacted != "sshpass -p "+Mask+" ssh host "+Mask {
+		t.Errorf("Unexpected redacted output: %q", redacted)
+	}
+
+	if !IsReference("${env:SSH_PASS}") || IsReference("$HOME") {
+		t.Errorf("IsReference returned unexpected results")
+	}
+}
@@ -0,0 +1,82 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+	"github.com/takutakahashi/operation-mcp/pkg/secrets"
+)
+
+// contextLookups maps a from_context source to the function that reads it.
+// It is a variable so tests can replace the lookups.
+var contextLookups = map[string]func() (string, error){
+	config.ContextKubeContext: func() (string, error) {
+		return runLookup("kubectl", "config", "current-context")
+	},
+	config.ContextKubeNamespace: func() (string, error) {
+		return runLookup("kubectl", "config", "view", "--minify", "-o", "jsonpath={..namespace}")
+	},
+}
+
+// runLookup runs a command and returns its trimmed output
+func runLookup(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveParamValues fills in parameters the caller did not provide.
+// Values are taken in the following order of precedence:
+//  1. the value given by the caller
+//  2. the environment variable named by env
+//  3. the context source named by from_context
+//  4. the default value
+//
+// The given map is not modified; a new map is returned.
+func ResolveParamValues(params map[string]config.Parameter, values map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		result[name] = value
+	}
+
+	for name, param := range params {
+		if value, exists := result[name]; exists && value != "" {
+			continue
+		}
+
+		if param.Env != "" {
+			if value := os.Getenv(param.Env); value != "" {
+				result[name] = value
+				continue
+			}
+		}
+
+		if param.FromContext != "" {
+			lookup, ok := contextLookups[param.FromContext]
+			if !ok {
+				return nil, fmt.Errorf("unknown from_context source for parameter %s: %s", name, param.FromContext)
+			}
+			// A failing lookup (e.g. kubectl not installed) falls through to the default
+			if value, err := lookup(); err == nil && value != "" {
+				result[name] = value
+				continue
+			}
+		}
+
+		if param.Default != "" {
+			// Defaults may be secret references, resolved only when used
+			value, err := secrets.Resolve(param.Default)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving default for parameter %s: %w", name, err)
+			}
+			result[name] = value
+		}
+	}
+
+	return result, nil
+}
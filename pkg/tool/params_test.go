@@ -0,0 +1,72 @@
+package tool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+func TestResolveParamValues(t *testing.T) {
+	// Replace context lookups so the test does not depend on kubectl
+	orig := contextLookups
+	defer func() { contextLookups = orig }()
+	contextLookups = map[string]func() (string, error){
+		config.ContextKubeContext: func() (string, error) {
+			return "test-cluster", nil
+		},
+		config.ContextKubeNamespace: func() (string, error) {
+			return "", fmt.Errorf("kubectl not available")
+		},
+	}
+
+	t.Setenv("TEST_NAMESPACE", "from-env")
+	t.Setenv("TEST_SECRET_TOKEN", "s3cr3t")
+
+	params := map[string]config.Parameter{
+		"namespace": {Type: "string", Env: "TEST_NAMESPACE", Default: "default"},
+		"context":   {Type: "string", FromContext: config.ContextKubeContext, Default: "fallback"},
+		"ns_ctx":    {Type: "string", FromContext: config.ContextKubeNamespace, Default: "fallback"},
+		"unset_env": {Type: "string", Env: "TEST_UNSET_VARIABLE", Default: "default"},
+		"plain":     {Type: "string"},
+		"token":     {Type: "string", Default: "${env:TEST_SECRET_TOKEN}"},
+	}
+
+	// Caller values take precedence over everything else
+	values, err := ResolveParamValues(params, map[string]string{"namespace": "from-flag"})
+	if err != nil {
+		t.Fatalf("ResolveParamValues failed: %v", err)
+	}
+	if values["namespace"] != "from-flag" {
+		t.Errorf("Expected namespace 'from-flag', got '%s'", values["namespace"])
+	}
+
+	values, err = ResolveParamValues(params, map[string]string{})
+	if err != nil {
+		t.Fatalf("ResolveParamValues failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"namespace": "from-env",
+		"context":   "test-cluster",
+		"ns_ctx":    "fallback",
+		"unset_env": "default",
+		"token":     "s3cr3t",
+	}
+	for name, want := range expected {
+		if values[name] != want {
+			t.Errorf("Expected %s '%s', got '%s'", name, want, values[name])
+		}
+	}
+	if _, exists := values["plain"]; exists {
+		t.Errorf("Expected parameter without fallback to stay unset")
+	}
+
+	// Unknown context sources are rejected
+	_, err = ResolveParamValues(map[string]config.Parameter{
+		"x": {Type: "string", FromContext: "unknown"},
+	}, map[string]string{})
+	if err == nil {
+		t.Errorf("ResolveParamValues should fail for unknown from_context source")
+	}
+}
@@ -0,0 +1,143 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+// omitMarker is rendered by the omit function. Any argument containing it is
+// dropped from the final command.
+const omitMarker = "\x00omit\x00"
+
+// argFuncs returns the functions available to argument templates. All of them
+// are free of side effects; env can only read the variables in allowedEnv.
+func argFuncs(allowedEnv []string) template.FuncMap {
+	return template.FuncMap{
+		// omit drops the argument it appears in
+		config.OmitFunc: func() string {
+			return omitMarker
+		},
+		// optional renders its value, or drops the argument when the value is empty
+		"optional": func(v interface{}) string {
+			if isEmpty(v) {
+				return omitMarker
+			}
+			return fmt.Sprint(v)
+		},
+		"default": func(def interface{}, v interface{}) interface{} {
+			if isEmpty(v) {
+				return def
+			}
+			return v
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"split": func(sep string, s string) []string {
+			return strings.Split(s, sep)
+		},
+		"replace": func(old, new, s string) string {
+			return strings.ReplaceAll(s, old, new)
+		},
+		"quote": strconv.Quote,
+		"toJson": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"env": func(name string) (string, error) {
+			for _, allowed := range allowedEnv {
+				if allowed == name {
+					return os.Getenv(name), nil
+				}
+			}
+			return "", fmt.Errorf("environment variable %s is not in template_env", name)
+		},
+		"now": time.Now,
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+}
+
+// isEmpty reports whether a template value is unset or empty
+func isEmpty(v interface{}) bool {
+	return v == nil || fmt.Sprint(v) == ""
+}
+
+// templateData builds the data passed to argument templates. Every declared
+// parameter is present, so that only references to undeclared parameters
+// fail with missingkey=error.
+func templateData(params map[string]config.Parameter, paramValues map[string]string) map[string]string {
+	data := make(map[string]string, len(params)+len(paramValues))
+	for name := range params {
+		data[name] = ""
+	}
+	for name, value := range paramValues {
+		data[name] = value
+	}
+	return data
+}
+
+// renderArgs replaces template parameters in command args. Arguments that
+// render to an omitted value are removed from the result.
+func renderArgs(command []string, data map[string]string, funcs template.FuncMap) ([]string, error) {
+	finalCommand := make([]string, 0, len(command))
+	for _, arg := range command {
+		rendered, ok, err := renderValue(arg, "argument", data, funcs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			finalCommand = append(finalCommand, rendered)
+		}
+	}
+
+	if len(finalCommand) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return finalCommand, nil
+}
+
+// renderValue replaces template parameters in a single value, described by
+// what in errors. It reports false when the value renders to an omitted
+// value.
+func renderValue(text, what string, data map[string]string, funcs template.FuncMap) (string, bool, error) {
+	if !strings.Contains(text, "{{") {
+		return text, true, nil
+	}
+
+	tmpl, err := template.New("arg").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", false, fmt.Errorf("error parsing template in %s: %w", what, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", false, fmt.Errorf("error executing template in %s: %w", what, err)
+	}
+
+	rendered := buf.String()
+	if strings.Contains(rendered, omitMarker) {
+		return "", false, nil
+	}
+	return rendered, true, nil
+}
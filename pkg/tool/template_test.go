@@ -0,0 +1,124 @@
+package tool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/takutakahashi/operation-mcp/pkg/config"
+)
+
+func TestRenderArgs(t *testing.T) {
+	command := []string{
+		"kubectl", "logs", "{{.pod}}",
+		"{{if .container}}-c{{else}}{{omit}}{{end}}",
+		"{{if .container}}{{.container}}{{else}}{{omit}}{{end}}",
+		"{{optional .since}}",
+	}
+
+	params := map[string]config.Parameter{
+		"pod":       {Type: "string"},
+		"container": {Type: "string"},
+		"since":     {Type: "string"},
+	}
+	funcs := argFuncs(nil)
+
+	// Optional arguments are dropped when their parameters are unset
+	got, err := renderArgs(command, templateData(params, map[string]string{"pod": "web"}), funcs)
+	if err != nil {
+		t.Fatalf("renderArgs failed: %v", err)
+	}
+	expected := []string{"kubectl", "logs", "web"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Empty values are treated as unset
+	got, err = renderArgs(command, map[string]string{"pod": "web", "container": "", "since": ""}, funcs)
+	if err != nil {
+		t.Fatalf("renderArgs failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Optional arguments are included when their parameters are set
+	got, err = renderArgs(command, map[string]string{"pod": "web", "container": "app", "since": "1h"}, funcs)
+	if err != nil {
+		t.Fatalf("renderArgs failed: %v", err)
+	}
+	expected = []string{"kubectl", "logs", "web", "-c", "app", "1h"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// A command that renders to nothing is an error
+	if _, err := renderArgs([]string{"{{optional .cmd}}"}, map[string]string{"cmd": ""}, funcs); err == nil {
+		t.Errorf("renderArgs should fail when every argument is omitted")
+	}
+}
+
+func TestArgFuncsMatchConfig(t *testing.T) {
+	// The config package checks templates against these names
+	funcs := argFuncs(nil)
+	if len(funcs) != len(config.TemplateFuncs) {
+		t.Errorf("Expected %d functions, got %d", len(config.TemplateFuncs), len(funcs))
+	}
+	for _, name := range config.TemplateFuncs {
+		if _, ok := funcs[name]; !ok {
+			t.Errorf("Expected function %s to be provided", name)
+		}
+	}
+}
+
+func TestRenderArgsFuncs(t *testing.T) {
+	t.Setenv("TEST_ALLOWED", "allowed")
+	t.Setenv("TEST_SECRET", "secret")
+	funcs := argFuncs([]string{"TEST_ALLOWED"})
+
+	data := map[string]string{
+		"name":  " Web ",
+		"items": "a,b,c",
+		"empty": "",
+	}
+
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{`{{default "fallback" .empty}}`, "fallback"},
+		{`{{default "fallback" .name}}`, " Web "},
+		{`{{lower .name}}`, " web "},
+		{`{{upper .name}}`, " WEB "},
+		{`{{trim .name}}`, "Web"},
+		{`{{split "," .items | join ";"}}`, "a;b;c"},
+		{`{{replace "," "-" .items}}`, "a-b-c"},
+		{`{{quote .items}}`, `"a,b,c"`},
+		{`{{toJson .items}}`, `"a,b,c"`},
+		{`{{b64enc "hello"}}`, "aGVsbG8="},
+		{`{{env "TEST_ALLOWED"}}`, "allowed"},
+		{`{{date "2006" now | len}}`, "4"},
+	}
+
+	for _, tt := range tests {
+		got, err := renderArgs([]string{tt.tmpl}, data, funcs)
+		if err != nil {
+			t.Errorf("renderArgs(%s) failed: %v", tt.tmpl, err)
+			continue
+		}
+		if got[0] != tt.expected {
+			t.Errorf("renderArgs(%s): expected %q, got %q", tt.tmpl, tt.expected, got[0])
+		}
+	}
+
+	// Environment variables outside the allow-list cannot be read
+	if _, err := renderArgs([]string{`{{env "TEST_SECRET"}}`}, data, funcs); err == nil {
+		t.Errorf("renderArgs should fail for environment variable outside template_env")
+	}
+
+	// References to undeclared parameters fail instead of rendering <no value>
+	_, err := renderArgs([]string{"{{.namspace}}"}, data, funcs)
+	if err == nil || !strings.Contains(err.Error(), "namspace") {
+		t.Errorf("renderArgs should fail for undeclared parameter, got: %v", err)
+	}
+}
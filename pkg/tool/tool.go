@@ -1,125 +1,265 @@
 package tool
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/takutakahashi/operation-mcp/pkg/config"
 	"github.com/takutakahashi/operation-mcp/pkg/danger"
 	"github.com/takutakahashi/operation-mcp/pkg/executor"
+	"github.com/takutakahashi/operation-mcp/pkg/secrets"
 )
 
 // Info represents a tool or subtool for hierarchical display
 type Info struct {
-	Name        string
-	Description string
-	Params      map[string]config.Parameter
-	Subtools    []Info
+	Name            string
+	Description     string
+	LongDescription string
+	Examples        []string
+	Aliases         []string
+	Deprecated      *config.Deprecation
+	Source          string
+	Params          map[string]config.Parameter
+	Subtools        []Info
 }
 
 // Manager handles tool execution
 type Manager struct {
 	config        *config.Config
 	dangerManager *danger.Manager
+	targets       *executor.Registry
 	execInstance  executor.Executor
+	target        string
+
+	// pool holds the connections to targets, shared by all tool calls
+	pool *executor.Pool
+
+	// selector selects several targets to run on at once, with fanOut
+	// overriding the fanout settings of the configuration
+	selector string
+	fanOut   config.FanOutConfig
 }
 
 // NewManager creates a new tool manager
 func NewManager(cfg *config.Config) *Manager {
+	pool := executor.NewPool(executor.PoolOptions{})
 	return &Manager{
 		config:        cfg,
 		dangerManager: danger.NewManager(cfg.Actions),
+		targets:       executor.NewRegistry(cfg.Targets, nil).WithPool(pool),
+		pool:          pool,
 	}
 }
 
-// WithExecutor sets the executor for the tool manager
+// Close closes the connections to targets
+func (m *Manager) Close() error {
+	return m.pool.Close()
+}
+
+// Config returns the configuration of the manager
+func (m *Manager) Config() *config.Config {
+	return m.config
+}
+
+// WithExecutor sets an executor that runs every tool, regardless of the
+// targets in the configuration
 func (m *Manager) WithExecutor(exec executor.Executor) {
 	m.execInstance = exec
 }
 
+// WithTarget sets the target every tool runs on, overriding the target in
+// the configuration
+func (m *Manager) WithTarget(name string) {
+	m.target = name
+}
+
+// WithTargetSelector runs every tool on all targets matching a selector at
+// once. Non-zero fields of fanOut override the fanout settings of the
+// configuration.
+func (m *Manager) WithTargetSelector(selector string, fanOut config.FanOutConfig) {
+	m.selector = selector
+	m.fanOut = fanOut
+}
+
 // FindTool finds a tool by its name
 func (m *Manager) FindTool(toolPath string) ([]string, map[string]config.Parameter, string, error) {
+	resolved, err := m.resolveTool(toolPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return resolved.command, resolved.params, resolved.dangerLevel, nil
+}
+
+// resolvedTool is a tool or subtool found by its path
+type resolvedTool struct {
+	command     []string
+	params      map[string]config.Parameter
+	dangerLevel string
+
+	// target is the default target and targets the allowed targets
+	target  string
+	targets []string
+
+	// rollout sets how the tool runs on several targets in batches
+	rollout *config.Rollout
+
+	// workdir, env and sudo set the environment the tool runs in
+	workdir string
+	env     config.Env
+	sudo    *config.Sudo
+
+	// transfer makes the tool copy a file instead of running a command
+	transfer *config.Transfer
+
+	// tty runs the tool on a terminal, for interactive commands
+	tty bool
+
+	// deprecations holds the notices of the deprecated tool and subtool
+	// on the path
+	deprecations []string
+}
+
+// resolveTool finds a tool or subtool by its path. Each part of the path
+// may be the name or an alias.
+func (m *Manager) resolveTool(toolPath string) (*resolvedTool, error) {
 	parts := strings.Split(toolPath, "_")
 	if len(parts) < 1 {
-		return nil, nil, "", fmt.Errorf("invalid tool path: %s", toolPath)
+		return nil, fmt.Errorf("invalid tool path: %s", toolPath)
 	}
 
 	// Find the root tool
 	var rootTool *config.Tool
 	for i := range m.config.Tools {
-		if m.config.Tools[i].Name == parts[0] {
+		if matchesName(parts[0], m.config.Tools[i].Name, m.config.Tools[i].Aliases) {
 			rootTool = &m.config.Tools[i]
 			break
 		}
 	}
 
 	if rootTool == nil {
-		return nil, nil, "", fmt.Errorf("tool not found: %s", parts[0])
+		return nil, fmt.Errorf("tool not found: %s", parts[0])
+	}
+
+	resolved := &resolvedTool{
+		target:  rootTool.Target,
+		targets: rootTool.Targets,
+		rollout: rootTool.Rollout,
+		workdir: rootTool.Workdir,
+		env:     make(config.Env),
+		sudo:    rootTool.Sudo,
+	}
+	for name, value := range rootTool.Env {
+		resolved.env[name] = value
+	}
+	if rootTool.Deprecated != nil {
+		resolved.deprecations = append(resolved.deprecations, rootTool.Deprecated.Notice("tool "+rootTool.Name))
 	}
 
 	// Start with the root tool's command
-	command := make([]string, len(rootTool.Command))
-	copy(command, rootTool.Command)
+	resolved.command = make([]string, len(rootTool.Command))
+	copy(resolved.command, rootTool.Command)
 
 	// Collect all parameters
-	params := make(map[string]config.Parameter)
+	resolved.params = make(map[string]config.Parameter)
 	for name, param := range rootTool.Params {
-		params[name] = param
+		resolved.params[name] = param
 	}
 
 	// If we only have the root tool, return it
 	if len(parts) == 1 {
-		return command, params, "", nil
+		return resolved, nil
 	}
 
-	// Navigate through subtools
-	var currentSubtool *config.Subtool
-	dangerLevel := ""
-
 	// Join the remaining parts to form the subtool path
 	subtoolPath := strings.Join(parts[1:], "_")
 
 	// Find the matching subtool
-	found := false
+	var currentSubtool *config.Subtool
 	for j := range rootTool.Subtools {
-		subtoolName := strings.ReplaceAll(rootTool.Subtools[j].Name, " ", "_")
-		if subtoolName == subtoolPath {
+		if matchesName(subtoolPath, rootTool.Subtools[j].Name, rootTool.Subtools[j].Aliases) {
 			currentSubtool = &rootTool.Subtools[j]
-			found = true
 			break
 		}
 	}
 
-	if !found {
-		return nil, nil, "", fmt.Errorf("subtool not found: %s", toolPath)
+	if currentSubtool == nil {
+		return nil, fmt.Errorf("subtool not found: %s", toolPath)
+	}
+
+	if currentSubtool.Deprecated != nil {
+		name := rootTool.Name + "_" + strings.ReplaceAll(currentSubtool.Name, " ", "_")
+		resolved.deprecations = append(resolved.deprecations, currentSubtool.Deprecated.Notice("tool "+name))
 	}
 
 	// Add subtool parameters
 	for name, param := range currentSubtool.Params {
-		params[name] = param
+		resolved.params[name] = param
 	}
 
 	// Update danger level if specified
-	if currentSubtool.DangerLevel != "" {
-		dangerLevel = currentSubtool.DangerLevel
+	resolved.dangerLevel = currentSubtool.DangerLevel
+
+	// Targets of the subtool replace those of the tool
+	if currentSubtool.Target != "" || len(currentSubtool.Targets) > 0 {
+		resolved.target = currentSubtool.Target
+		resolved.targets = currentSubtool.Targets
+	}
+	if currentSubtool.Rollout != nil {
+		resolved.rollout = currentSubtool.Rollout
+	}
+
+	// The environment of the subtool extends the one of the tool
+	if currentSubtool.Workdir != "" {
+		resolved.workdir = currentSubtool.Workdir
+	}
+	for name, value := range currentSubtool.Env {
+		resolved.env[name] = value
+	}
+	if currentSubtool.Sudo != nil {
+		resolved.sudo = currentSubtool.Sudo
 	}
+	resolved.transfer = currentSubtool.Transfer
+	resolved.tty = currentSubtool.TTY
 
 	// Add the args from the final subtool
-	if currentSubtool != nil {
-		command = append(command, currentSubtool.Args...)
+	resolved.command = append(resolved.command, currentSubtool.Args...)
+
+	return resolved, nil
+}
+
+// matchesName reports whether a path part refers to a tool or subtool by its
+// name or one of its aliases. Spaces in names and aliases match underscores.
+func matchesName(part, name string, aliases []string) bool {
+	for _, candidate := range append([]string{name}, aliases...) {
+		if strings.ReplaceAll(candidate, " ", "_") == part {
+			return true
+		}
 	}
+	return false
+}
 
-	return command, params, dangerLevel, nil
+// warnDeprecated prints the deprecation notices of a tool to stderr
+func warnDeprecated(resolved *resolvedTool) {
+	for _, notice := range resolved.deprecations {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", notice)
+	}
 }
 
 // ExecuteTool executes a tool with the given parameters
 func (m *Manager) ExecuteTool(toolPath string, paramValues map[string]string) error {
 	// Find the tool
-	command, params, dangerLevel, err := m.FindTool(toolPath)
+	resolved, err := m.resolveTool(toolPath)
+	if err != nil {
+		return err
+	}
+	params, dangerLevel := resolved.params, resolved.dangerLevel
+	warnDeprecated(resolved)
+
+	// Fill in unset parameters from env, context and defaults
+	paramValues, err = ResolveParamValues(params, paramValues)
 	if err != nil {
 		return err
 	}
@@ -134,6 +274,24 @@ func (m *Manager) ExecuteTool(toolPath string, paramValues map[string]string) er
 		}
 	}
 
+	// Replace template parameters in command args and the environment
+	// before any danger check, so template errors are reported without
+	// prompting
+	rendered, err := m.render(resolved, paramValues)
+	if err != nil {
+		return err
+	}
+
+	// Choose where the tool runs before asking for confirmation
+	targets, err := m.selectTargets(resolved, toolPath)
+	if err != nil {
+		return err
+	}
+	healthCheck, err := m.healthCheck(resolved, paramValues)
+	if err != nil {
+		return err
+	}
+
 	// Check danger level for parameters with validation rules
 	for name, param := range params {
 		value, exists := paramValues[name]
@@ -146,7 +304,8 @@ func (m *Manager) ExecuteTool(toolPath string, paramValues map[string]string) er
 					param.Validate,
 				)
 				if err != nil {
-					return err
+					// The error contains the value, which may be a secret
+					return errors.New(secrets.Redact(err.Error()))
 				}
 				if !proceed {
 					return fmt.Errorf("operation aborted due to danger level check")
@@ -166,43 +325,432 @@ func (m *Manager) ExecuteTool(toolPath string, paramValues map[string]string) er
 		}
 	}
 
-	// Replace template parameters in command args
-	finalCommand := make([]string, len(command))
-	for i, arg := range command {
-		if strings.Contains(arg, "{{") {
-			tmpl, err := template.New("arg").Parse(arg)
-			if err != nil {
-				return fmt.Errorf("error parsing template in argument: %w", err)
+	// Execute the command
+	return m.run(resolved, targets, rendered, healthCheck)
+}
+
+// selectTarget returns the target a tool runs on. The target set with
+// WithTarget takes precedence over the one in the configuration, but must be
+// one of the tool's targets when it lists them.
+func (m *Manager) selectTarget(resolved *resolvedTool, toolPath string) (string, error) {
+	target := resolved.target
+	if m.target != "" {
+		target = m.target
+	}
+
+	if len(resolved.targets) > 0 {
+		if target == "" {
+			if len(resolved.targets) > 1 {
+				return "", fmt.Errorf("tool %s runs on several targets (%s); choose one with --target",
+					toolPath, strings.Join(resolved.targets, ", "))
 			}
+			target = resolved.targets[0]
+		} else if !containsString(resolved.targets, target) {
+			return "", fmt.Errorf("tool %s cannot run on target %s (allowed: %s)",
+				toolPath, target, strings.Join(resolved.targets, ", "))
+		}
+	}
 
-			var buf bytes.Buffer
-			if err := tmpl.Execute(&buf, paramValues); err != nil {
-				return fmt.Errorf("error executing template in argument: %w", err)
+	return target, nil
+}
+
+// selectTargets returns the targets a tool runs on: those matching the
+// selector set with WithTargetSelector, or otherwise a single target
+func (m *Manager) selectTargets(resolved *resolvedTool, toolPath string) ([]string, error) {
+	if m.selector == "" {
+		target, err := m.selectTarget(resolved, toolPath)
+		if err != nil {
+			return nil, err
+		}
+		return []string{target}, nil
+	}
+
+	targets, err := m.targets.Select(m.selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved.targets) > 0 {
+		for _, target := range targets {
+			if !containsString(resolved.targets, target) {
+				return nil, fmt.Errorf("tool %s cannot run on target %s (allowed: %s)",
+					toolPath, target, strings.Join(resolved.targets, ", "))
 			}
+		}
+	}
+	return targets, nil
+}
+
+// healthCheck renders the health check of a tool rolled out across several
+// targets, or returns nil when the tool is not rolled out. The health check
+// tool receives the parameters it shares with the tool.
+func (m *Manager) healthCheck(resolved *resolvedTool, paramValues map[string]string) (*renderedTool, error) {
+	if !m.rollsOut(resolved) || resolved.rollout.HealthCheck == "" {
+		return nil, nil
+	}
+
+	check, err := m.resolveTool(resolved.rollout.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("health check: %w", err)
+	}
 
-			finalCommand[i] = buf.String()
-		} else {
-			finalCommand[i] = arg
+	values := make(map[string]string)
+	for name := range check.params {
+		if value, ok := paramValues[name]; ok {
+			values[name] = value
+		}
+	}
+	values, err = ResolveParamValues(check.params, values)
+	if err != nil {
+		return nil, fmt.Errorf("health check %s: %w", resolved.rollout.HealthCheck, err)
+	}
+	for name, param := range check.params {
+		if param.Required && values[name] == "" {
+			return nil, fmt.Errorf("health check %s: required parameter missing: %s", resolved.rollout.HealthCheck, name)
 		}
 	}
 
-	// Execute the command
-	fmt.Printf("Executing: %s\n", strings.Join(finalCommand, " "))
-	cmd := exec.Command(finalCommand[0], finalCommand[1:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	rendered, err := m.render(check, values)
+	if err != nil {
+		return nil, fmt.Errorf("health check %s: %w", resolved.rollout.HealthCheck, err)
+	}
+	return rendered, nil
+}
+
+// rollsOut reports whether a tool runs on the selected targets in batches
+func (m *Manager) rollsOut(resolved *resolvedTool) bool {
+	return m.execInstance == nil && m.selector != "" && resolved.rollout != nil
+}
+
+// run executes a rendered tool on the selected targets
+func (m *Manager) run(resolved *resolvedTool, targets []string, rendered *renderedTool, healthCheck *renderedTool) error {
+	if rendered.transfer != nil {
+		return m.runTransfer(targets, rendered.transfer)
+	}
+
+	if rendered.env != nil && rendered.env.TTY && m.selector != "" {
+		return fmt.Errorf("tools with tty run on a single target; choose one with --target")
+	}
+
+	command := rendered.command
+	display := secrets.Redact(strings.Join(command, " "))
+
+	// An executor set with WithExecutor runs every tool
+	if m.execInstance != nil {
+		fmt.Printf("Executing: %s\n", display)
+		if rendered.env == nil {
+			return m.execInstance.Execute(command)
+		}
+		exec, ok := m.execInstance.(executor.EnvironmentExecutor)
+		if !ok {
+			return fmt.Errorf("the executor cannot set the working directory, environment or sudo user")
+		}
+		return exec.ExecuteIn(rendered.env, command)
+	}
+
+	if m.rollsOut(resolved) {
+		return m.runRollout(resolved.rollout, targets, rendered, healthCheck, display)
+	}
+	if m.selector != "" {
+		return m.runFanOut(targets, rendered, display)
+	}
+
+	target := targets[0]
+
+	factory, err := m.targets.FactoryWithOptions(target, executor.NewOptions().WithEnvironment(rendered.env))
+	if err != nil {
+		return err
+	}
+	exec, err := factory.CreateExecutor()
+	if err != nil {
+		return fmt.Errorf("failed to connect to target %s: %w", target, err)
+	}
+	defer exec.Close()
 
-	return cmd.Run()
+	if target == "" || target == config.LocalTarget {
+		fmt.Printf("Executing: %s\n", display)
+	} else {
+		fmt.Printf("Executing on %s: %s\n", target, display)
+	}
+	return exec.Execute(command)
+}
+
+// runTransfer copies a file between the local machine and a single target
+func (m *Manager) runTransfer(targets []string, transfer *transfer) error {
+	if m.selector != "" {
+		return fmt.Errorf("transfers run on a single target; choose one with --target")
+	}
+	target := targets[0]
+
+	exec := m.execInstance
+	if exec == nil {
+		factory, err := m.targets.Factory(target)
+		if err != nil {
+			return err
+		}
+		if exec, err = factory.CreateExecutor(); err != nil {
+			return fmt.Errorf("failed to connect to target %s: %w", target, err)
+		}
+		defer exec.Close()
+	}
+	transferrer, ok := exec.(executor.Transferrer)
+	if !ok {
+		return fmt.Errorf("the executor cannot transfer files")
+	}
+
+	// Name the other end of the transfer in messages
+	remote := target
+	switch {
+	case m.execInstance != nil:
+		remote = "remote"
+	case remote == "":
+		remote = config.LocalTarget
+	}
+
+	var n int64
+	var err error
+	if transfer.upload {
+		fmt.Printf("Uploading %s to %s:%s\n", transfer.source, remote, transfer.destination)
+		n, err = transferrer.Upload(transfer.source, transfer.destination, transfer.options)
+	} else {
+		fmt.Printf("Downloading %s:%s to %s\n", remote, transfer.source, transfer.destination)
+		n, err = transferrer.Download(transfer.source, transfer.destination, transfer.options)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Transferred %d bytes\n", n)
+	return nil
+}
+
+// runFanOut executes a rendered command on several targets at once and
+// prints a summary of the results
+func (m *Manager) runFanOut(targets []string, rendered *renderedTool, display string) error {
+	fmt.Printf("Executing on %d targets (%s): %s\n", len(targets), strings.Join(targets, ", "), display)
+	options := m.fanOutOptions()
+	options.Environment = rendered.env
+	results := m.targets.FanOut(targets, rendered.command, options)
+	return printResults(results)
+}
+
+// runRollout executes a rendered command on several targets in batches,
+// running the health check after each batch, and prints a summary of the
+// results. Danger levels have been confirmed once for the whole rollout.
+func (m *Manager) runRollout(rollout *config.Rollout, targets []string, rendered *renderedTool, healthCheck *renderedTool, display string) error {
+	options := executor.RolloutOptions{
+		FanOutOptions: m.fanOutOptions(),
+		BatchSize:     rollout.BatchSize,
+		BatchPercent:  rollout.BatchPercent,
+		Pause:         time.Duration(rollout.Pause) * time.Second,
+	}
+	options.Environment = rendered.env
+	if healthCheck != nil {
+		options.HealthCheck = healthCheck.command
+		options.HealthCheckEnvironment = healthCheck.env
+	}
+	batches := executor.Batches(targets, options.BatchSize, options.BatchPercent)
+
+	fmt.Printf("Rolling out to %d targets in %d batches: %s\n", len(targets), len(batches), display)
+	if healthCheck != nil {
+		fmt.Printf("Health check: %s\n", secrets.Redact(strings.Join(healthCheck.command, " ")))
+	}
+	result := m.targets.Rollout(targets, rendered.command, options)
+
+	err := printResults(result.Results)
+	if result.Err != nil {
+		return fmt.Errorf("rollout stopped: %w", result.Err)
+	}
+	return err
+}
+
+// fanOutOptions returns the fanout settings of the configuration with the
+// overrides set with WithTargetSelector applied
+func (m *Manager) fanOutOptions() executor.FanOutOptions {
+	settings := m.fanOut
+	if m.config.FanOut != nil {
+		settings = mergeFanOut(*m.config.FanOut, m.fanOut)
+	}
+	return executor.FanOutOptions{
+		Concurrency: settings.Concurrency,
+		FailFast:    settings.FailFast,
+		MaxFailures: settings.MaxFailures,
+		Timeout:     time.Duration(settings.Timeout) * time.Second,
+	}
+}
+
+// printResults prints a summary of the results on each target and returns an
+// error unless every target succeeded
+func printResults(results []executor.ExecutionResult) error {
+	summary := executor.Summarize(results)
+	fmt.Printf("\nSummary: %s\n", summary)
+	for _, result := range results {
+		fmt.Printf("  %s: %s\n", result.Target, resultStatus(result))
+	}
+
+	if summary.Succeeded != len(results) {
+		return fmt.Errorf("%d of %d targets did not succeed", len(results)-summary.Succeeded, len(results))
+	}
+	return nil
+}
+
+// mergeFanOut returns the fanout settings of the configuration with the
+// non-zero fields of overrides applied
+func mergeFanOut(settings, overrides config.FanOutConfig) config.FanOutConfig {
+	if overrides.Concurrency > 0 {
+		settings.Concurrency = overrides.Concurrency
+	}
+	if overrides.FailFast {
+		settings.FailFast = true
+	}
+	if overrides.MaxFailures > 0 {
+		settings.MaxFailures = overrides.MaxFailures
+	}
+	if overrides.Timeout > 0 {
+		settings.Timeout = overrides.Timeout
+	}
+	return settings
+}
+
+// resultStatus describes the result of running a command on one target
+func resultStatus(result executor.ExecutionResult) string {
+	switch {
+	case result.Skipped:
+		return "skipped"
+	case result.TimedOut:
+		return fmt.Sprintf("timed out (%s)", result.Duration.Round(time.Millisecond))
+	case result.Err != nil:
+		return fmt.Sprintf("failed (%s): %v", result.Duration.Round(time.Millisecond), result.Err)
+	default:
+		return fmt.Sprintf("succeeded (%s)", result.Duration.Round(time.Millisecond))
+	}
+}
+
+// containsString reports whether a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCommand renders the command templates with the tool's parameters
+func (m *Manager) renderCommand(command []string, params map[string]config.Parameter, paramValues map[string]string) ([]string, error) {
+	var allowedEnv []string
+	if m.config != nil {
+		allowedEnv = m.config.TemplateEnv
+	}
+	return renderArgs(command, templateData(params, paramValues), argFuncs(allowedEnv))
+}
+
+// renderedTool is a tool ready to run: its command and the environment it
+// runs in, or the file it transfers, with template parameters replaced
+type renderedTool struct {
+	command  []string
+	env      *executor.Environment
+	transfer *transfer
+}
+
+// transfer is a file transfer ready to run
+type transfer struct {
+	upload              bool
+	source, destination string
+	options             executor.TransferOptions
+}
+
+// render renders the command and the environment of a tool, or its
+// transfer, with its parameters. The environment is nil when the tool sets
+// none.
+func (m *Manager) render(resolved *resolvedTool, paramValues map[string]string) (*renderedTool, error) {
+	if resolved.transfer != nil {
+		transfer, err := m.renderTransfer(resolved.transfer, resolved.params, paramValues)
+		if err != nil {
+			return nil, err
+		}
+		return &renderedTool{transfer: transfer}, nil
+	}
+
+	command, err := m.renderCommand(resolved.command, resolved.params, paramValues)
+	if err != nil {
+		return nil, err
+	}
+	rendered := &renderedTool{command: command}
+	if resolved.workdir == "" && len(resolved.env) == 0 && resolved.sudo == nil && !resolved.tty {
+		return rendered, nil
+	}
+
+	var allowedEnv []string
+	if m.config != nil {
+		allowedEnv = m.config.TemplateEnv
+	}
+	data, funcs := templateData(resolved.params, paramValues), argFuncs(allowedEnv)
+
+	env := &executor.Environment{Env: make(map[string]string), TTY: resolved.tty}
+	if env.Dir, _, err = renderValue(resolved.workdir, "workdir", data, funcs); err != nil {
+		return nil, err
+	}
+	// Variables rendering to an omitted value are not set
+	for _, name := range resolved.env.Names() {
+		value, ok, err := renderValue(resolved.env[name], "environment variable "+name, data, funcs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			env.Env[name] = value
+		}
+	}
+	if resolved.sudo != nil {
+		env.Sudo = &executor.Sudo{User: resolved.sudo.User, NonInteractive: resolved.sudo.NonInteractive}
+	}
+
+	rendered.env = env
+	return rendered, nil
+}
+
+// renderTransfer renders the source and destination of a transfer
+func (m *Manager) renderTransfer(settings *config.Transfer, params map[string]config.Parameter, paramValues map[string]string) (*transfer, error) {
+	var allowedEnv []string
+	if m.config != nil {
+		allowedEnv = m.config.TemplateEnv
+	}
+	data, funcs := templateData(params, paramValues), argFuncs(allowedEnv)
+
+	source, ok, err := renderValue(settings.Source, "transfer source", data, funcs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || source == "" {
+		return nil, fmt.Errorf("transfer source is empty")
+	}
+	destination, ok, err := renderValue(settings.Destination, "transfer destination", data, funcs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || destination == "" {
+		return nil, fmt.Errorf("transfer destination is empty")
+	}
+
+	maxSize := settings.MaxSize
+	if maxSize == 0 {
+		maxSize = config.DefaultMaxTransferSize
+	}
+	return &transfer{
+		upload:      settings.Direction == config.TransferUpload,
+		source:      source,
+		destination: destination,
+		options:     executor.TransferOptions{MaxSize: maxSize},
+	}, nil
 }
 
 // ExecuteRawTool executes a tool with the given raw arguments
 func (m *Manager) ExecuteRawTool(toolPath string, args []string) error {
 	// Find the tool and subtool
-	command, params, dangerLevel, err := m.FindTool(toolPath)
+	resolved, err := m.resolveTool(toolPath)
 	if err != nil {
 		return err
 	}
+	params, dangerLevel := resolved.params, resolved.dangerLevel
+	warnDeprecated(resolved)
 
 	// Extract parameter values from the command-line arguments
 	paramValues := make(map[string]string)
@@ -229,15 +777,10 @@ func (m *Manager) ExecuteRawTool(toolPath string, args []string) error {
 		}
 	}
 
-	// Check danger level for the subtool
-	if dangerLevel != "" {
-		proceed, err := m.dangerManager.CheckDangerLevel(dangerLevel, "", "", nil)
-		if err != nil {
-			return err
-		}
-		if !proceed {
-			return fmt.Errorf("operation aborted due to danger level check")
-		}
+	// Fill in unset parameters from env, context and defaults
+	paramValues, err = ResolveParamValues(params, paramValues)
+	if err != nil {
+		return err
 	}
 
 	// Validate required parameters
@@ -250,50 +793,59 @@ func (m *Manager) ExecuteRawTool(toolPath string, args []string) error {
 		}
 	}
 
-	// Replace template parameters in command args
-	finalCommand := make([]string, len(command))
-	for i, arg := range command {
-		if strings.Contains(arg, "{{") {
-			tmpl, err := template.New("arg").Parse(arg)
-			if err != nil {
-				return fmt.Errorf("error parsing template in argument: %w", err)
-			}
+	// Replace template parameters in command args and the environment
+	// before any danger check, so template errors are reported without
+	// prompting
+	rendered, err := m.render(resolved, paramValues)
+	if err != nil {
+		return err
+	}
 
-			var buf bytes.Buffer
-			if err := tmpl.Execute(&buf, paramValues); err != nil {
-				return fmt.Errorf("error executing template in argument: %w", err)
-			}
+	// Choose where the tool runs before asking for confirmation
+	targets, err := m.selectTargets(resolved, toolPath)
+	if err != nil {
+		return err
+	}
+	healthCheck, err := m.healthCheck(resolved, paramValues)
+	if err != nil {
+		return err
+	}
 
-			finalCommand[i] = buf.String()
-		} else {
-			finalCommand[i] = arg
+	// Check danger level for the subtool
+	if dangerLevel != "" {
+		proceed, err := m.dangerManager.CheckDangerLevel(dangerLevel, "", "", nil)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			return fmt.Errorf("operation aborted due to danger level check")
 		}
 	}
 
 	// Execute the command
-	fmt.Printf("Executing: %s\n", strings.Join(finalCommand, " "))
-	cmd := exec.Command(finalCommand[0], finalCommand[1:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	return cmd.Run()
+	return m.run(resolved, targets, rendered, healthCheck)
 }
 
 // ListTools returns all tools and subtools defined in the config
 func (m *Manager) ListTools() []Info {
-	if m.config == nil || len(m.config.Tools) == 0 {
+	cfg := m.Config()
+	if cfg == nil || len(cfg.Tools) == 0 {
 		return []Info{}
 	}
 
-	result := make([]Info, 0, len(m.config.Tools))
+	result := make([]Info, 0, len(cfg.Tools))
 
-	for _, tool := range m.config.Tools {
+	for _, tool := range cfg.Tools {
 		toolInfo := Info{
-			Name:        tool.Name,
-			Description: "", // Config doesn't have description field for tools
-			Params:      tool.Params,
-			Subtools:    make([]Info, 0, len(tool.Subtools)),
+			Name:            tool.Name,
+			Description:     tool.Description,
+			LongDescription: tool.LongDescription,
+			Examples:        tool.Examples,
+			Aliases:         tool.Aliases,
+			Deprecated:      tool.Deprecated,
+			Source:          tool.Pos.File,
+			Params:          tool.Params,
+			Subtools:        make([]Info, 0, len(tool.Subtools)),
 		}
 
 		// Add subtools recursively
@@ -312,10 +864,15 @@ func convertSubtoolToInfo(subtool config.Subtool, parentName string) Info {
 	name := strings.ReplaceAll(subtool.Name, " ", "_")
 
 	toolInfo := Info{
-		Name:        name,
-		Description: "", // Config doesn't have description field for subtools
-		Params:      subtool.Params,
-		Subtools:    make([]Info, 0, len(subtool.Subtools)),
+		Name:            name,
+		Description:     subtool.Description,
+		LongDescription: subtool.LongDescription,
+		Examples:        subtool.Examples,
+		Aliases:         subtool.Aliases,
+		Deprecated:      subtool.Deprecated,
+		Source:          subtool.Pos.File,
+		Params:          subtool.Params,
+		Subtools:        make([]Info, 0, len(subtool.Subtools)),
 	}
 
 	// Add nested subtools recursively
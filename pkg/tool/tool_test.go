@@ -2,6 +2,9 @@ package tool
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/takutakahashi/operation-mcp/pkg/config"
@@ -187,3 +190,382 @@ func TestExecuteRawTool(t *testing.T) {
 		t.Errorf("ExecuteRawTool should fail when required parameter is missing")
 	}
 }
+
+func TestExcludedSecretIsRedacted(t *testing.T) {
+	t.Setenv("TEST_EXCLUDED_TOKEN", "s3cr3t-token")
+
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:    "echo",
+				Command: []string{"echo"},
+				Params: map[string]config.Parameter{
+					"token": {
+						Type:    "string",
+						Default: "${env:TEST_EXCLUDED_TOKEN}",
+						Validate: []config.Validation{
+							{DangerLevel: "high", Exclude: []string{"s3cr3t-token"}},
+						},
+					},
+				},
+				Subtools: []config.Subtool{
+					{Name: "token", Args: []string{"{{.token}}"}},
+				},
+			},
+		},
+	})
+
+	err := mgr.ExecuteTool("echo_token", map[string]string{})
+	if err == nil {
+		t.Fatal("Expected the excluded value to be rejected")
+	}
+	if strings.Contains(err.Error(), "s3cr3t-token") {
+		t.Errorf("Expected the secret to be redacted, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), "parameter token with value") {
+		t.Errorf("Expected the exclude error, got %q", err.Error())
+	}
+}
+
+func TestListToolsDescriptions(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:        "kubectl",
+				Description: "Manage pods",
+				Command:     []string{"kubectl"},
+				Subtools: []config.Subtool{
+					{
+						Name:            "delete pod",
+						Description:     "Delete a pod",
+						LongDescription: "The pod is recreated by its controller.",
+						Examples:        []string{"operations kubectl delete_pod --pod web-0"},
+						Args:            []string{"delete", "pod"},
+					},
+				},
+			},
+		},
+	})
+
+	tools := mgr.ListTools()
+	if tools[0].Description != "Manage pods" {
+		t.Errorf("Expected tool description 'Manage pods', got '%s'", tools[0].Description)
+	}
+
+	subtool := tools[0].Subtools[0]
+	if subtool.Description != "Delete a pod" {
+		t.Errorf("Expected subtool description 'Delete a pod', got '%s'", subtool.Description)
+	}
+	if subtool.LongDescription != "The pod is recreated by its controller." {
+		t.Errorf("Expected subtool long description, got '%s'", subtool.LongDescription)
+	}
+	if len(subtool.Examples) != 1 || subtool.Examples[0] != "operations kubectl delete_pod --pod web-0" {
+		t.Errorf("Expected subtool examples, got %v", subtool.Examples)
+	}
+}
+
+func TestFindToolAliases(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:    "kubectl",
+				Aliases: []string{"k"},
+				Command: []string{"kubectl"},
+				Subtools: []config.Subtool{
+					{Name: "get pods", Aliases: []string{"ls", "list pods"}, Args: []string{"get", "pods"}},
+					{
+						Name:       "get pod",
+						Deprecated: &config.Deprecation{Message: "renamed", Replacement: "kubectl_get_pods"},
+						Args:       []string{"get", "pod"},
+					},
+				},
+			},
+		},
+	})
+
+	for _, path := range []string{"kubectl_get_pods", "k_get_pods", "kubectl_ls", "k_list_pods"} {
+		command, _, _, err := mgr.FindTool(path)
+		if err != nil {
+			t.Errorf("FindTool failed for %s: %v", path, err)
+			continue
+		}
+		if strings.Join(command, " ") != "kubectl get pods" {
+			t.Errorf("Expected 'kubectl get pods' for %s, got '%s'", path, strings.Join(command, " "))
+		}
+	}
+
+	// Deprecated subtools are still found, with a notice
+	resolved, err := mgr.resolveTool("k_get_pod")
+	if err != nil {
+		t.Fatalf("resolveTool failed: %v", err)
+	}
+	expected := "tool kubectl_get_pod is deprecated: renamed (use kubectl_get_pods instead)"
+	if len(resolved.deprecations) != 1 || resolved.deprecations[0] != expected {
+		t.Errorf("Expected deprecation %q, got %v", expected, resolved.deprecations)
+	}
+}
+
+func TestSelectTarget(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Targets: map[string]config.Target{
+			"bastion": {SSHConfig: config.SSHConfig{Host: "bastion"}},
+			"app1":    {SSHConfig: config.SSHConfig{Host: "app1"}},
+			"app2":    {SSHConfig: config.SSHConfig{Host: "app2"}},
+		},
+		Tools: []config.Tool{
+			{
+				Name:    "kubectl",
+				Target:  "bastion",
+				Command: []string{"kubectl"},
+				Subtools: []config.Subtool{
+					{Name: "get", Args: []string{"get"}},
+				},
+			},
+			{
+				Name:    "systemctl",
+				Targets: []string{"app1", "app2"},
+				Command: []string{"systemctl"},
+				Subtools: []config.Subtool{
+					{Name: "status", Args: []string{"status"}},
+					{Name: "restart", Target: "app1", Args: []string{"restart"}},
+				},
+			},
+			{Name: "echo", Command: []string{"echo"}},
+		},
+	})
+
+	tests := []struct {
+		path     string
+		flag     string
+		expected string
+		wantErr  bool
+	}{
+		{path: "echo", expected: ""},
+		{path: "kubectl_get", expected: "bastion"},
+		{path: "kubectl_get", flag: "app1", expected: "app1"},
+		{path: "systemctl_status", wantErr: true},
+		{path: "systemctl_status", flag: "app2", expected: "app2"},
+		{path: "systemctl_status", flag: "bastion", wantErr: true},
+		{path: "systemctl_restart", expected: "app1"},
+	}
+
+	for _, test := range tests {
+		mgr.WithTarget(test.flag)
+		resolved, err := mgr.resolveTool(test.path)
+		if err != nil {
+			t.Fatalf("resolveTool failed for %s: %v", test.path, err)
+		}
+
+		target, err := mgr.selectTarget(resolved, test.path)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %s with --target %q, got target %q", test.path, test.flag, target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("selectTarget failed for %s with --target %q: %v", test.path, test.flag, err)
+		} else if target != test.expected {
+			t.Errorf("Expected target %q for %s with --target %q, got %q", test.expected, test.path, test.flag, target)
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:    "systemctl",
+				Command: []string{"systemctl"},
+				Subtools: []config.Subtool{
+					{
+						Name: "restart",
+						Args: []string{"restart", "{{.unit}}"},
+						Params: config.Parameters{
+							"unit":  {Type: "string", Required: true},
+							"force": {Type: "boolean"},
+						},
+						Rollout: &config.Rollout{BatchSize: 2, HealthCheck: "systemctl_status"},
+					},
+					{
+						Name:   "status",
+						Args:   []string{"is-active", "{{.unit}}"},
+						Params: config.Parameters{"unit": {Type: "string", Required: true}},
+					},
+				},
+			},
+		},
+	})
+	resolved, err := mgr.resolveTool("systemctl_restart")
+	if err != nil {
+		t.Fatalf("resolveTool failed: %v", err)
+	}
+
+	// Without a target selector the tool is not rolled out
+	check, err := mgr.healthCheck(resolved, map[string]string{"unit": "nginx"})
+	if err != nil || check != nil {
+		t.Errorf("Expected no health check without a selector, got %v, %v", check, err)
+	}
+
+	// The health check receives the parameters it shares with the tool
+	mgr.WithTargetSelector("role=app", config.FanOutConfig{})
+	check, err = mgr.healthCheck(resolved, map[string]string{"unit": "nginx", "force": "true"})
+	if err != nil {
+		t.Fatalf("healthCheck failed: %v", err)
+	}
+	if strings.Join(check.command, " ") != "systemctl is-active nginx" {
+		t.Errorf("Expected health check command %q, got %q", "systemctl is-active nginx", strings.Join(check.command, " "))
+	}
+
+	if _, err := mgr.healthCheck(resolved, map[string]string{}); err == nil {
+		t.Errorf("Expected error for a missing health check parameter")
+	}
+}
+
+func TestRenderEnvironment(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:    "rails",
+				Command: []string{"bin/rails"},
+				Workdir: "/srv/{{.app}}",
+				Env:     config.Env{"RAILS_ENV": "production", "LOG_LEVEL": "info"},
+				Sudo:    &config.Sudo{User: "deploy"},
+				Params:  config.Parameters{"app": {Type: "string", Required: true}},
+				Subtools: []config.Subtool{
+					{
+						Name: "migrate",
+						Args: []string{"db:migrate"},
+						Env: config.Env{
+							"LOG_LEVEL": "debug",
+							"VERSION":   "{{optional .version}}",
+						},
+						Params: config.Parameters{"version": {Type: "string"}},
+					},
+				},
+			},
+			{Name: "echo", Command: []string{"echo"}},
+		},
+	})
+
+	resolved, err := mgr.resolveTool("rails_migrate")
+	if err != nil {
+		t.Fatalf("resolveTool failed: %v", err)
+	}
+	rendered, err := mgr.render(resolved, map[string]string{"app": "shop"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	env := rendered.env
+	if env == nil || env.Dir != "/srv/shop" {
+		t.Fatalf("Expected workdir /srv/shop, got %+v", env)
+	}
+	// The subtool's variables extend and override those of the tool, and
+	// omitted values are not set
+	expected := map[string]string{"RAILS_ENV": "production", "LOG_LEVEL": "debug"}
+	if !reflect.DeepEqual(env.Env, expected) {
+		t.Errorf("Expected env %v, got %v", expected, env.Env)
+	}
+	if env.Sudo == nil || env.Sudo.User != "deploy" || env.Sudo.NonInteractive {
+		t.Errorf("Unexpected sudo: %+v", env.Sudo)
+	}
+
+	// Tools without an environment run in the default one
+	resolved, err = mgr.resolveTool("echo")
+	if err != nil {
+		t.Fatalf("resolveTool failed: %v", err)
+	}
+	rendered, err = mgr.render(resolved, nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if rendered.env != nil {
+		t.Errorf("Expected no environment, got %+v", rendered.env)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(src, []byte("listen 80\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", src, err)
+	}
+
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name: "files",
+				Subtools: []config.Subtool{
+					{
+						Name: "push",
+						Transfer: &config.Transfer{
+							Direction:   config.TransferUpload,
+							Source:      src,
+							Destination: filepath.Join(dir, "{{.name}}"),
+						},
+						Params: config.Parameters{"name": {Type: "string", Required: true}},
+					},
+					{
+						Name: "pull",
+						Transfer: &config.Transfer{
+							Direction:   config.TransferDownload,
+							Source:      src,
+							Destination: filepath.Join(dir, "small.conf"),
+							MaxSize:     5,
+						},
+					},
+				},
+			},
+		},
+	})
+	defer mgr.Close()
+
+	if err := mgr.ExecuteTool("files_push", map[string]string{"name": "copy.conf"}); err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "copy.conf"))
+	if err != nil || string(data) != "listen 80\n" {
+		t.Errorf("Expected the file to be copied, got %q, %v", string(data), err)
+	}
+
+	if err := mgr.ExecuteTool("files_pull", nil); err == nil || !strings.Contains(err.Error(), "limit of 5 bytes") {
+		t.Errorf("Expected size limit error, got %v", err)
+	}
+
+	// Transfers do not fan out
+	mgr.WithTargetSelector(config.LocalTarget, config.FanOutConfig{})
+	if err := mgr.ExecuteTool("files_push", map[string]string{"name": "other.conf"}); err == nil {
+		t.Errorf("Expected error for a transfer on several targets")
+	}
+}
+
+func TestTTY(t *testing.T) {
+	mgr := NewManager(&config.Config{
+		Tools: []config.Tool{
+			{
+				Name:     "kubectl",
+				Command:  []string{"kubectl"},
+				Subtools: []config.Subtool{{Name: "exec", Args: []string{"exec", "-it", "app", "--", "sh"}, TTY: true}},
+			},
+		},
+	})
+	defer mgr.Close()
+
+	resolved, err := mgr.resolveTool("kubectl_exec")
+	if err != nil {
+		t.Fatalf("resolveTool failed: %v", err)
+	}
+	rendered, err := mgr.render(resolved, nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if rendered.env == nil || !rendered.env.TTY {
+		t.Errorf("Expected a tty environment, got %+v", rendered.env)
+	}
+
+	// Tools with tty do not fan out
+	mgr.WithTargetSelector(config.LocalTarget, config.FanOutConfig{})
+	if err := mgr.ExecuteTool("kubectl_exec", nil); err == nil || !strings.Contains(err.Error(), "single target") {
+		t.Errorf("Expected error for a tty tool on several targets, got %v", err)
+	}
+}